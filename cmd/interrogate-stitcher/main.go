@@ -2,6 +2,9 @@ package main
 
 import (
 	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
 
 	"voidedtech.com/interrogate/internal"
 )
@@ -11,14 +14,74 @@ func main() {
 	dir := flag.String("dir", "", "directory to use")
 	cfg := flag.String("config", "", "configuration file")
 	out := flag.String("out", "", "output file naming (prefix)")
+	auto := flag.Bool("auto", false, "discover the index and run configs in the directory")
+	join := flag.Bool("join", false, "join the runs discovered in each directory (arguments) by client")
+	orphans := flag.Bool("orphans", false, "include sessions with results that are not indexed (e.g. abandoned)")
 	flag.Parse()
+	if *join {
+		joinStitch(flag.Args(), *out, *orphans)
+		return
+	}
+	if *auto {
+		autoStitch(*dir, *out, *orphans)
+		return
+	}
 	in := internal.Inputs{
 		Manifest:  *manifest,
 		Config:    *cfg,
 		Directory: *dir,
 		OutName:   *out,
+		Orphans:   *orphans,
 	}
 	if err := in.Process(); err != nil {
 		internal.Fatal("processing failure", err)
 	}
 }
+
+func autoStitch(dir, out string, orphans bool) {
+	inputs, err := internal.AutoInputs(dir, out)
+	if err != nil {
+		internal.Fatal("unable to discover inputs", err)
+	}
+	stitched := 0
+	for _, in := range inputs {
+		in.Orphans = orphans
+		if err := in.Process(); err != nil {
+			internal.Error(fmt.Sprintf("unable to stitch %s with %s", in.Manifest, in.Config), err)
+			continue
+		}
+		internal.Info(fmt.Sprintf("stitched: %s", in.OutName))
+		stitched++
+	}
+	if stitched == 0 {
+		internal.Fatal("processing failure", fmt.Errorf("no results stitched"))
+	}
+}
+
+func joinStitch(dirs []string, out string, orphans bool) {
+	if len(dirs) == 0 {
+		internal.Fatal("processing failure", fmt.Errorf("no directories to join"))
+	}
+	var inputs []internal.Inputs
+	for _, dir := range dirs {
+		// NOTE: runs are named by their directory, tag and run config
+		found, err := internal.AutoInputs(dir, filepath.Base(filepath.Clean(dir)))
+		if err != nil {
+			internal.Fatal("unable to discover inputs", err)
+		}
+		for idx := range found {
+			found[idx].Orphans = orphans
+		}
+		inputs = append(inputs, found...)
+	}
+	name := internal.SetIfEmpty(out, "results") + internal.JoinedCSV
+	f, err := os.Create(name)
+	if err != nil {
+		internal.Fatal("unable to create output", err)
+	}
+	defer f.Close()
+	if err := internal.Join(inputs, f); err != nil {
+		internal.Fatal("processing failure", err)
+	}
+	internal.Info(fmt.Sprintf("joined: %s", name))
+}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"bytes"
+	"encoding/csv"
 	"encoding/json"
 	"flag"
 	"fmt"
@@ -10,6 +12,7 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -22,42 +25,118 @@ const (
 	staticURL        = "/static/"
 	surveyURL        = "/survey/"
 	surveyClientURL  = surveyURL + "%d/%s"
+	surveyPageURL    = surveyURL + "%s/%d%s"
 	questionFileName = "questions"
+	inviteFileName   = "invites"
+	inviteRequired   = "This survey is by invitation only, please use the link you were sent"
+	inviteUnknown    = "This invitation is not valid"
+	inviteCompleted  = "This invitation has already been used, thank you for participating"
+	sessionCookie    = "interrogate_session"
+	sessionForeign   = "This survey session was started in another browser"
+	bearerPrefix     = "Bearer "
+	signerFileName   = "session.key"
+	masksFileName    = "client.masks"
 	qReset           = "RESET"
-	saveFileName     = "save"
+	saveFileName     = internal.SaveMode
+	snapshotMode     = internal.SnapshotMode
+	apiURL           = "/api/v1/"
+	mountURL         = "/s/"
+	liveKeepAlive    = 15 * time.Second
+	liveCoalesce     = time.Second
+	sessionExpiry    = 24 * time.Hour
+	sessionLimit     = 10000
 )
 
 var (
-	lock      = &sync.Mutex{}
-	mask      = &sync.Mutex{}
-	clientIDs = make(map[string]string)
-	knownIDs  = make(map[string]string)
+	lock = &sync.Mutex{}
 )
 
 type (
-	// Context represent operating context
+	// Context represent operating context of a (mounted) survey
 	Context struct {
 		http.Handler
-		snapshot     int
-		tag          string
-		store        string
+		*server
+		name     string
+		base     string
+		initial  string
+		snapshot int
+		fixedTag string
+		sets     sync.RWMutex
+		active   *surveySet
+		sessions map[string]*boundSession
+		// retired sets were replaced (switched) and are closed once no session is bound to them
+		retired []*surveySet
+		// invites are the participant codes (sessions) allowed when running by invitation only
+		invites *internal.Invites
+		// update is the latest result waiting to be published to the live view (see publish)
+		updates sync.Mutex
+		update  *liveUpdate
+	}
+
+	// liveUpdate is a result to publish to the live view
+	liveUpdate struct {
+		set     *surveySet
+		client  string
+		session string
+		mode    string
+	}
+
+	// server is the operating context shared by all surveys
+	server struct {
+		storage      string
+		storeBackend string
+		indexBackend string
+		searchDir    string
 		temp         string
 		beginTmpl    *template.Template
 		surveyTmpl   *template.Template
 		completeTmpl *template.Template
+		closedTmpl   *template.Template
 		adminTmpl    *template.Template
-		questions    []internal.Field
-		title        string
+		liveTmpl     *template.Template
 		staticPath   string
 		available    []string
-		cfgName      string
-		memoryConfig string
 		serveStatic  string
 		masking      bool
 		showMask     bool
 		adminUser    string
 		adminPass    string
 		anonymous    bool
+		mounts       []*Context
+		events       *internal.Broker
+		signer       *internal.Signer
+		masks        *internal.Masks
+		pseudonymKey string
+		identity     *internal.Identity
+	}
+
+	// surveySet is a loaded question set and where its results are written
+	surveySet struct {
+		name         string
+		cfgName      string
+		tag          string
+		store        string
+		questions    []internal.Field
+		pages        int
+		title        string
+		memoryConfig string
+		results      internal.ResultStore
+		live         *internal.Live
+		maxResponses int
+		quotas       []*internal.Quota
+		closedText   string
+		schedule     *internal.Schedule
+		autoBundle   bool
+	}
+
+	// boundSession is the question set a session started on
+	boundSession struct {
+		set  *surveySet
+		seen time.Time
+	}
+
+	conditionBlock struct {
+		id string
 	}
 
 	initSurvey struct {
@@ -71,28 +150,26 @@ type (
 	}
 )
 
-func (ctx *Context) newSet(configFile string) error {
+func (set *surveySet) newSet(configFile, staticPath string) error {
 	data, err := ioutil.ReadFile(configFile)
 	if err != nil {
 		return err
 	}
-	var config internal.Config
-	if err := yaml.Unmarshal(data, &config); err != nil {
+	// NOTE: definitions are checked by lint, the set is built from a valid definition
+	config, err := internal.LoadConfig(data)
+	if err != nil {
 		return err
 	}
-	ctx.title = config.Metadata.Title
+	set.title = config.Metadata.Title
 	var mapping []internal.Field
 	number := 0
-	inCond := false
-	condCount := 0
+	page := 1
+	var conds []*conditionBlock
+	explicit := internal.ExplicitEnds(config.Questions)
 	exports := &internal.Exports{}
 	for _, q := range config.Questions {
-		condCount++
-		k := number
+		k, key := q.Keys(number)
 		number = number + 1
-		if q.Numbered > 0 {
-			k = q.Numbered
-		}
 		field := &internal.Field{}
 		for _, attr := range q.Attributes {
 			switch attr {
@@ -101,11 +178,13 @@ func (ctx *Context) newSet(configFile string) error {
 			}
 		}
 		field.ID = k
+		field.Key = key
 		field.Text = q.Text
 		field.Basis = q.Basis
 		field.Height = q.Height
 		field.Width = q.Width
 		field.Description = q.Description
+		field.Randomize = q.Randomize
 		defaultDimensions := false
 		switch q.Type {
 		case "input":
@@ -145,6 +224,8 @@ func (ctx *Context) newSet(configFile string) error {
 			field.Video = true
 		case "hr":
 			field.HorizontalFeed = true
+		case "pagebreak":
+			field.PageBreak = true
 		case "slide", "uslide":
 			field.Slider = true
 			field.SlideID = template.JS(fmt.Sprintf("slide%d", k))
@@ -152,151 +233,462 @@ func (ctx *Context) newSet(configFile string) error {
 			field.Basis = internal.SetIfEmpty(field.Basis, "50")
 			field.SlideValues = q.Type == "slide"
 		case "conditional":
-			if inCond {
-				if condCount == 1 {
-					internal.Fatal("conditional contains no questions", nil)
-				}
+			if q.ClosesConditional(explicit, len(conds)) {
 				field.CondEnd = true
-				inCond = false
+				conds = conds[0 : len(conds)-1]
 			} else {
-				condCount = 0
-				inCond = true
 				field.CondStart = true
 			}
 		default:
-			internal.Fatal("unknown question type: "+q.Type, nil)
+			return fmt.Errorf("unknown question type: %s", q.Type)
 		}
 		if field.Image || field.Audio || field.Video {
-			field.Basis = fmt.Sprintf("%s%s", ctx.staticPath, field.Basis)
+			field.Basis = fmt.Sprintf("%s%s", staticPath, field.Basis)
 		}
 		if defaultDimensions {
 			field.Height = internal.SetIfEmpty(field.Height, "250")
 			field.Width = internal.SetIfEmpty(field.Width, "250")
 		}
+		if len(conds) > 0 && !field.CondEnd {
+			field.AddCondition(&internal.Condition{Clauses: []internal.Clause{{ID: conds[len(conds)-1].id, Op: internal.CheckedOp}}})
+		}
+		if field.CondStart {
+			conds = append(conds, &conditionBlock{id: field.Key})
+		}
+		if q.ShowIf != "" {
+			cond, err := internal.ParseCondition(q.ShowIf, false)
+			if err != nil {
+				return err
+			}
+			field.AddCondition(cond)
+		}
+		field.Piped = len(internal.PipeRefs(q.Text)) > 0 || len(internal.PipeRefs(q.Description)) > 0
 		field.Group = q.Group
+		field.Page = page
+		if field.PageBreak {
+			page++
+		}
+		field.Type = q.Type
 		field.RawType = internal.CreateHash(-1, q.Type)
 		field.Hash = internal.CreateHash(field.ID, field.Text)
 		mapping = append(mapping, *field)
-		exports.Fields = append(exports.Fields, &internal.ExportField{Text: field.Text, Type: q.Type})
+		exports.Fields = append(exports.Fields, &internal.ExportField{Key: field.Key, Text: field.Text, Type: q.Type, Options: field.Options, Required: field.Required != ""})
 	}
-	if inCond {
-		internal.Fatal("unclosed conditional", nil)
+	for idx, q := range config.Questions {
+		if q.SkipIf == "" {
+			continue
+		}
+		skip, err := internal.ParseCondition(q.SkipIf, true)
+		if err != nil {
+			return err
+		}
+		for i := idx + 1; i < len(mapping) && mapping[i].Key != q.SkipTo; i++ {
+			mapping[i].AddCondition(skip)
+		}
+	}
+	for _, group := range config.Metadata.Randomize {
+		for i := range mapping {
+			if mapping[i].Group == group {
+				mapping[i].RandomGroup = group
+			}
+		}
 	}
-	ctx.questions = mapping
+	for _, q := range config.Metadata.Quotas {
+		quota, err := internal.NewQuota(q)
+		if err != nil {
+			return err
+		}
+		set.quotas = append(set.quotas, quota)
+	}
+	schedule, err := internal.NewSchedule(config.Metadata)
+	if err != nil {
+		return err
+	}
+	set.schedule = schedule
+	set.autoBundle = config.Metadata.Bundle
+	set.maxResponses = config.Metadata.MaxResponses
+	set.closedText = internal.SetIfEmpty(config.Metadata.Closed, internal.ClosedMessage)
+	set.questions = mapping
+	set.pages = page
 	datum, err := json.Marshal(exports)
 	if err != nil {
 		internal.Error("unable to write memory config", err)
 		return err
 	}
-	exportConf := filepath.Join(ctx.store, fmt.Sprintf("run.config.%s", internal.TimeString()))
+	exportConf := filepath.Join(set.store, internal.RunConfigName(set.tag))
 	if err := ioutil.WriteFile(exportConf, datum, 0644); err != nil {
 		return err
 	}
 	internal.Info(fmt.Sprintf("running config: %s", exportConf))
-	ctx.memoryConfig = exportConf
+	set.memoryConfig = exportConf
 	return nil
 }
 
-func homeEndpoint(resp http.ResponseWriter, req *http.Request, ctx *Context) {
-	pd := ctx.newPage(req)
-	pd.Session = internal.NewSession(20)
-	pd.HandleTemplate(resp, ctx.beginTmpl)
+// loadSet loads a question set (by name) writing results under the given tag
+func (ctx *Context) loadSet(name, tag string) (*surveySet, error) {
+	set := &surveySet{
+		name:    name,
+		cfgName: filepath.Join(ctx.searchDir, name),
+		tag:     tag,
+		store:   filepath.Join(ctx.storage, tag),
+	}
+	if err := os.MkdirAll(set.store, 0755); err != nil {
+		return nil, err
+	}
+	if err := set.newSet(fmt.Sprintf("%s%s", set.cfgName, internal.ConfigExt), ctx.staticPath); err != nil {
+		return nil, err
+	}
+	results, err := internal.OpenResultStore(ctx.storeBackend, set.store, set.tag, ctx.indexBackend)
+	if err != nil {
+		return nil, err
+	}
+	set.results = results
+	live, err := internal.NewLive(results, set.memoryConfig)
+	if err != nil {
+		return nil, err
+	}
+	set.live = live
+	if set.autoBundle {
+		set.schedule.OnClose(func() {
+			internal.Info(fmt.Sprintf("survey closed, archiving tag %s", set.tag))
+			archive(ctx, set)
+		})
+	}
+	return set, nil
 }
 
-func completeEndpoint(resp http.ResponseWriter, req *http.Request, ctx *Context) {
-	pd := ctx.newPage(req)
-	pd.HandleTemplate(resp, ctx.completeTmpl)
+func (ctx *Context) current() *surveySet {
+	ctx.sets.RLock()
+	defer ctx.sets.RUnlock()
+	return ctx.active
 }
 
-func (ctx *Context) getManifest() (string, *internal.Manifest, error) {
-	return internal.ReadManifestFile(ctx.store, ctx.tag)
+// forSession gets the question set a session started on (the active set for sessions not bound to one)
+func (ctx *Context) forSession(sess string) *surveySet {
+	ctx.sets.Lock()
+	defer ctx.sets.Unlock()
+	if b, ok := ctx.sessions[sess]; ok {
+		b.seen = time.Now()
+		return b.set
+	}
+	return ctx.active
 }
 
-func reindex(client, filename string, ctx *Context, mode string) {
+// bindSet binds a (created or saving) session to the question set it started on
+func (ctx *Context) bindSet(sess string, set *surveySet) {
+	ctx.sets.Lock()
+	defer ctx.sets.Unlock()
+	now := time.Now()
+	if b, ok := ctx.sessions[sess]; ok {
+		b.seen = now
+		return
+	}
+	if len(ctx.sessions) >= sessionLimit {
+		ctx.expire(now)
+	}
+	ctx.sessions[sess] = &boundSession{set: set, seen: now}
+}
+
+// expire drops the bindings of sessions not seen recently (the least recently seen bound to the active set when there are too many)
+func (ctx *Context) expire(now time.Time) {
+	var oldest string
+	for sess, b := range ctx.sessions {
+		if now.Sub(b.seen) > sessionExpiry {
+			delete(ctx.sessions, sess)
+			continue
+		}
+		// NOTE: sessions on a retired set are never evicted early, unbound sessions continue on the active set
+		if b.set != ctx.active {
+			continue
+		}
+		if oldest == "" || b.seen.Before(ctx.sessions[oldest].seen) {
+			oldest = sess
+		}
+	}
+	if len(ctx.sessions) >= sessionLimit && oldest != "" {
+		delete(ctx.sessions, oldest)
+	}
+}
+
+func (ctx *Context) endSession(sess string) {
+	ctx.sets.Lock()
+	delete(ctx.sessions, sess)
+	ctx.sets.Unlock()
+	ctx.release()
+}
+
+// release closes the results of retired sets no session is bound to
+func (ctx *Context) release() {
+	// NOTE: results are written holding the global lock, a set is never closed mid-write
 	lock.Lock()
 	defer lock.Unlock()
-	handled := false
-	fname, existing, err := ctx.getManifest()
-	if err != nil {
+	ctx.sets.Lock()
+	defer ctx.sets.Unlock()
+	if len(ctx.retired) == 0 {
 		return
 	}
-	for i, c := range existing.Clients {
-		if c == client {
-			curMode := existing.Modes[i]
-			update := false
-			// if we currently have a 'save' we only overwrite with another 'save'
-			if curMode == saveFileName {
-				if mode == saveFileName {
-					update = true
-				}
-			} else {
-				update = true
-			}
-			if update {
-				existing.Files[i] = filename
-				existing.Modes[i] = mode
-			}
-			handled = true
-			break
+	used := make(map[*surveySet]bool)
+	for _, b := range ctx.sessions {
+		used[b.set] = true
+	}
+	var retired []*surveySet
+	for _, set := range ctx.retired {
+		if used[set] {
+			retired = append(retired, set)
+			continue
+		}
+		internal.Info(fmt.Sprintf("closing results of tag %s", set.tag))
+		if err := set.results.Close(); err != nil {
+			internal.Error("unable to close results", err)
 		}
 	}
-	if !handled {
-		existing.Clients = append(existing.Clients, client)
-		existing.Files = append(existing.Files, filename)
-		existing.Modes = append(existing.Modes, mode)
+	ctx.retired = retired
+}
+
+func (ctx *Context) nextTag() string {
+	tag := internal.TimeString()
+	if ctx.fixedTag != "" {
+		tag = fmt.Sprintf("%s-%s", ctx.fixedTag, tag)
+	}
+	use := tag
+	for i := 1; internal.PathExists(filepath.Join(ctx.storage, use)); i++ {
+		use = fmt.Sprintf("%s-%d", tag, i)
 	}
-	existing.Write(fname)
+	return use
 }
 
-func saveData(data *internal.ResultData, ctx *Context, mode string, client string, session string) {
-	name := ""
-	for _, c := range strings.ToLower(fmt.Sprintf("%s_%s_%s", client, internal.NewSession(6), session)) {
-		if (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') || (c == '_') {
-			name = name + string(c)
+// switchSet swaps the active question set, sessions already started keep their original set
+func (ctx *Context) switchSet(name string, bundling bool) error {
+	old := ctx.current()
+	if bundling {
+		internal.Info("bundling")
+		bundle(ctx, old)
+	}
+	set, err := ctx.loadSet(name, ctx.nextTag())
+	if err != nil {
+		return err
+	}
+	// NOTE: the old set keeps its schedule (e.g. bundling on close) until it is replaced
+	old.schedule.Stop()
+	ctx.sets.Lock()
+	ctx.active = set
+	ctx.retired = append(ctx.retired, old)
+	ctx.sets.Unlock()
+	internal.Info(fmt.Sprintf("switched question set: %s (tag %s)", set.name, set.tag))
+	ctx.publish(set, "", "", "")
+	ctx.release()
+	return nil
+}
+
+// closed checks if a (session's) result would exceed the response limit or a full quota its answers match (nil answers only checks the limit)
+func (set *surveySet) closed(sess string, answers map[string][]string) bool {
+	if set.maxResponses > 0 && set.results.Manifest().Completed(sess) >= set.maxResponses {
+		return true
+	}
+	if answers == nil {
+		return false
+	}
+	for _, q := range set.quotas {
+		if q.Matches(answers) && q.Counted(set.live, sess).Full() {
+			return true
 		}
 	}
-	data.Datum[internal.ClientKey] = []string{client}
-	ts := internal.TimeString()
-	data.Datum[internal.TimestampKey] = []string{ts}
-	fname := fmt.Sprintf("%s_%s_%s_%s", ctx.tag, ts, mode, name)
-	go reindex(client, fname, ctx, mode)
-	j, err := internal.NewFile(ctx.store, fname+".json")
-	if mode == saveFileName {
-		internal.Info(fmt.Sprintf("save %s", fname))
+	return false
+}
+
+// closedMessage is shown to participants turned away from a survey
+func (set *surveySet) closedMessage() string {
+	if set.schedule.Pending(time.Now()) {
+		return fmt.Sprintf("This survey opens %s", set.schedule.Opens())
 	}
+	return set.closedText
+}
+
+// started checks if a session is in progress (bound to a set or with saved answers)
+func (ctx *Context) started(sess string) bool {
+	ctx.sets.RLock()
+	_, ok := ctx.sessions[sess]
+	ctx.sets.RUnlock()
+	if ok {
+		return true
+	}
+	// NOTE: sessions bindings are not kept across restarts
+	existing, err := ctx.current().results.LoadSession(sess)
+	return err == nil && existing != nil
+}
+
+// cookieName is the cookie a survey's session is bound to
+func (ctx *Context) cookieName() string {
+	if ctx.name == "" {
+		return sessionCookie
+	}
+	return fmt.Sprintf("%s_%s", sessionCookie, internal.CleanName(ctx.name))
+}
+
+// bindSession issues the (signed) cookie binding a session to the browser
+func (ctx *Context) bindSession(resp http.ResponseWriter, sess string) {
+	http.SetCookie(resp, &http.Cookie{
+		Name:     ctx.cookieName(),
+		Value:    ctx.signer.Sign(sess),
+		Path:     ctx.base + "/",
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// authorized checks a request is bound to a session, by its cookie or (api) bearer token
+func (ctx *Context) authorized(req *http.Request, sess string) bool {
+	if sess == "" {
+		return false
+	}
+	if ctx.cookieSession(req) == sess {
+		return true
+	}
+	auth := req.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, bearerPrefix) {
+		return false
+	}
+	token, ok := ctx.signer.Verify(strings.TrimPrefix(auth, bearerPrefix))
+	return ok && token == sess
+}
+
+// cookieSession gets the session a request is bound to ("" if there is no valid cookie)
+func (ctx *Context) cookieSession(req *http.Request) string {
+	c, err := req.Cookie(ctx.cookieName())
 	if err != nil {
-		internal.Error("error writing json output", err)
+		return ""
+	}
+	sess, ok := ctx.signer.Verify(c.Value)
+	if !ok {
+		return ""
+	}
+	return sess
+}
+
+func (ctx *Context) closedPage(resp http.ResponseWriter, req *http.Request, message string) {
+	pd := ctx.newPage(req)
+	pd.Closed = message
+	pd.HandleTemplate(resp, ctx.closedTmpl)
+}
+
+// invitation checks a session is an issued (and not yet completed) invitation code, returning why not
+func (ctx *Context) invitation(sess string) string {
+	if !ctx.invites.Known(sess) {
+		return inviteUnknown
+	}
+	// NOTE: the manifest check covers codes completed before completions were recorded (with the same tag)
+	if ctx.invites.Completed(sess) || ctx.forSession(sess).results.Manifest().Saved(sess) {
+		return inviteCompleted
+	}
+	return ""
+}
+
+func homeEndpoint(resp http.ResponseWriter, req *http.Request, ctx *Context) {
+	if set := ctx.current(); set.closed("", nil) || !set.schedule.Open(time.Now()) {
+		ctx.closedPage(resp, req, set.closedMessage())
 		return
 	}
-	defer j.Close()
-	jsonString, err := json.Marshal(data)
+	if ctx.invites != nil {
+		ctx.closedPage(resp, req, inviteRequired)
+		return
+	}
+	pd := ctx.newPage(req)
+	pd.Session = internal.NewSession(20)
+	ctx.bindSet(pd.Session, ctx.current())
+	ctx.bindSession(resp, pd.Session)
+	ctx.identity.Bind(resp, req)
+	pd.HandleTemplate(resp, ctx.beginTmpl)
+}
+
+func completeEndpoint(resp http.ResponseWriter, req *http.Request, ctx *Context) {
+	pd := ctx.newPage(req)
+	pd.HandleTemplate(resp, ctx.completeTmpl)
+}
+
+func closedEndpoint(resp http.ResponseWriter, req *http.Request, ctx *Context) {
+	ctx.closedPage(resp, req, ctx.current().closedMessage())
+}
+
+// getManifest gets the index (with history) of a set
+func (set *surveySet) getManifest() (string, *internal.Manifest, error) {
+	return set.results.Path(), set.results.History(), nil
+}
+
+// saveData stores a result, false when a save is refused because the survey is closed (a limit is reached)
+func saveData(ctx *Context, data *internal.ResultData, set *surveySet, mode string, client string, session string) bool {
+	written, closed := writeData(data, set, mode, client, session)
+	if written {
+		ctx.publish(set, client, session, mode)
+	}
+	return !closed
+}
+
+// writeData stores (and tracks) a result, indicating if it was written or refused (closed)
+func writeData(data *internal.ResultData, set *surveySet, mode string, client string, session string) (bool, bool) {
+	// NOTE: results are written and indexed in order of arrival
+	lock.Lock()
+	defer lock.Unlock()
+	// NOTE: limits are checked holding the lock so concurrent saves can not exceed them
+	if mode == saveFileName && set.closed(session, data.Datum) {
+		return false, true
+	}
+	data.Datum[internal.ClientKey] = []string{client}
+	data.Datum[internal.TimestampKey] = []string{internal.TimeString()}
+	put := set.results.PutSnapshot
+	if mode == saveFileName {
+		put = set.results.PutSave
+	}
+	fname, err := put(client, session, data)
 	if err != nil {
-		internal.Error("unable to write json", err)
+		internal.Error("error writing results", err)
+		return false, false
+	}
+	if mode == saveFileName {
+		internal.Info(fmt.Sprintf("save %s", fname))
+	}
+	set.live.Record(client, session, mode, data)
+	return true, false
+}
+
+// publish sends the live state of a set (after a result) to the live view, when it is being watched
+func (ctx *Context) publish(set *surveySet, client, session, mode string) {
+	// NOTE: summarizing is a stitch of every session, it is skipped when nobody is watching
+	if !ctx.events.Watched() {
 		return
 	}
-	j.Write(jsonString)
+	ctx.updates.Lock()
+	defer ctx.updates.Unlock()
+	queued := ctx.update != nil
+	ctx.update = &liveUpdate{set: set, client: client, session: session, mode: mode}
+	// NOTE: results arriving together are published once (as the latest of them)
+	if !queued {
+		time.AfterFunc(liveCoalesce, ctx.flush)
+	}
 }
 
-func maskID(client string, purge bool) string {
-	mask.Lock()
-	defer mask.Unlock()
-	i, ok := clientIDs[client]
-	if !ok {
-		for {
-			uid := internal.NewSession(10)
-			if _, ok := knownIDs[uid]; ok {
-				time.Sleep(500 * time.Millisecond)
-				continue
-			}
-			i = uid
-			break
-		}
-		clientIDs[client] = i
+// flush publishes the queued live update
+func (ctx *Context) flush() {
+	ctx.updates.Lock()
+	u := ctx.update
+	ctx.update = nil
+	ctx.updates.Unlock()
+	if u == nil {
+		return
 	}
-	if purge {
-		delete(clientIDs, client)
+	event := u.set.live.Event(ctx.name, u.set.tag)
+	event.Client = u.client
+	event.Session = u.session
+	event.Mode = u.mode
+	ctx.events.Publish(event)
+}
+
+// maskID gets the mask of a client (see internal.Masks)
+func (ctx *Context) maskID(client string, purge bool) string {
+	m, err := ctx.masks.Mask(client, purge)
+	if err != nil {
+		internal.Error("unable to persist client masks", err)
 	}
-	return i
+	return m
 }
 
 func saveEndpoint(resp http.ResponseWriter, req *http.Request, ctx *Context) {
@@ -313,26 +705,194 @@ func saveEndpoint(resp http.ResponseWriter, req *http.Request, ctx *Context) {
 			sess = v[0]
 		}
 	}
+	if !ctx.authorized(req, sess) {
+		internal.Info(fmt.Sprintf("rejecting %s for session %s (not bound to this browser)", mode, sess))
+		writeJSON(resp, http.StatusForbidden, &internal.ValidationResult{Errors: map[string]string{internal.SessionKey: sessionForeign}})
+		return
+	}
+	if errs := ctx.submit(req, mode, sess, datum); len(errs) > 0 {
+		writeErrors(resp, errs)
+	}
+}
 
+func (ctx *Context) submit(req *http.Request, mode, sess string, datum map[string][]string) map[string]string {
+	if ctx.invites != nil {
+		if msg := ctx.invitation(sess); msg != "" {
+			return map[string]string{internal.ClosedKey: msg}
+		}
+	}
+	set := ctx.forSession(sess)
+	// NOTE: answers hidden by survey logic are discarded, not saved
+	skipped := internal.Skipped(set.questions, datum)
+	for _, k := range skipped {
+		delete(datum, k)
+	}
+	if errs := internal.Validate(set.questions, datum, skipped, mode == saveFileName); len(errs) > 0 {
+		internal.Info(fmt.Sprintf("rejecting %s for session %s (%d errors)", mode, sess, len(errs)))
+		return errs
+	}
+	if len(skipped) > 0 {
+		datum[internal.SkippedKey] = skipped
+	}
+	origin := ctx.identity.Client(req)
+	client := origin
+	purge := false
+	if ctx.invites != nil {
+		// NOTE: invited participants are identified by their code (not their connection)
+		client = sess
+		datum[internal.ParticipantKey] = []string{sess}
+	} else if ctx.pseudonymKey != "" {
+		client = internal.Pseudonym(ctx.pseudonymKey, client)
+	} else if ctx.masking {
+		client = ctx.maskID(client, false)
+		purge = ctx.anonymous && mode == saveFileName
+	}
+	if !set.schedule.Accepting(time.Now()) {
+		internal.Info(fmt.Sprintf("rejecting %s for session %s (outside of the survey window)", mode, sess))
+		return map[string]string{internal.ClosedKey: set.closedMessage()}
+	}
+	// NOTE: the presented order is derived from the session, not trusted from the submission
+	for k, v := range internal.Presented(set.questions, sess) {
+		datum[k] = v
+	}
 	r := &internal.ResultData{
 		Datum: datum,
 	}
-	client := internal.GetClient(req)
-	if ctx.masking {
-		client = maskID(client, ctx.anonymous && mode == saveFileName)
+	// NOTE: the binding keeps the set open (see release) until the session ends
+	ctx.bindSet(sess, set)
+	// NOTE: page navigation reads this back, it must be written before responding
+	if !saveData(ctx, r, set, mode, client, sess) {
+		internal.Info(fmt.Sprintf("rejecting %s for session %s (closed)", mode, sess))
+		return map[string]string{internal.ClosedKey: set.closedText}
+	}
+	if purge {
+		// NOTE: the mask is only forgotten once the save is written (a refused save keeps it)
+		ctx.maskID(origin, true)
+	}
+	if mode == saveFileName {
+		if ctx.invites != nil {
+			if err := ctx.invites.Complete(sess); err != nil {
+				internal.Error("unable to record completed invitation", err)
+			}
+		}
+		ctx.endSession(sess)
+	}
+	return nil
+}
+
+func apiSurveyEndpoint(resp http.ResponseWriter, req *http.Request, ctx *Context) {
+	if req.Method != http.MethodGet {
+		resp.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	set := ctx.current()
+	writeJSON(resp, http.StatusOK, internal.NewAPISurvey(set.title, set.tag, set.pages, set.questions))
+}
+
+func apiSessionEndpoint(resp http.ResponseWriter, req *http.Request, ctx *Context) {
+	sess, _ := internal.GetURLTuple(req, 4)
+	if sess == "" {
+		if req.Method != http.MethodPost {
+			resp.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if ctx.invites != nil {
+			writeErrors(resp, map[string]string{internal.ClosedKey: inviteRequired})
+			return
+		}
+		if set := ctx.current(); set.closed("", nil) || !set.schedule.Open(time.Now()) {
+			writeErrors(resp, map[string]string{internal.ClosedKey: set.closedMessage()})
+			return
+		}
+		sess := internal.NewSession(20)
+		ctx.bindSet(sess, ctx.current())
+		ctx.bindSession(resp, sess)
+		writeJSON(resp, http.StatusOK, &internal.APISession{Session: sess, Token: ctx.signer.Sign(sess)})
+		return
 	}
-	go saveData(r, ctx, mode, client, sess)
+	if !ctx.authorized(req, sess) {
+		writeJSON(resp, http.StatusForbidden, &internal.ValidationResult{Errors: map[string]string{internal.SessionKey: sessionForeign}})
+		return
+	}
+	switch req.Method {
+	case http.MethodGet:
+		existing, err := ctx.forSession(sess).results.LoadSession(sess)
+		if err != nil {
+			internal.Error("unable to read session state", err)
+			resp.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if existing == nil {
+			resp.WriteHeader(http.StatusNotFound)
+			return
+		}
+		writeJSON(resp, http.StatusOK, &internal.APISession{Session: sess, Answers: internal.NewAPIAnswers(existing.Datum)})
+	case http.MethodPost:
+		submission := &internal.APISubmission{}
+		if err := json.NewDecoder(req.Body).Decode(submission); err != nil {
+			writeJSON(resp, http.StatusBadRequest, &internal.ValidationResult{Errors: map[string]string{"body": err.Error()}})
+			return
+		}
+		mode := internal.SetIfEmpty(submission.Mode, snapshotMode)
+		if mode != saveFileName && mode != snapshotMode {
+			writeJSON(resp, http.StatusBadRequest, &internal.ValidationResult{Errors: map[string]string{"mode": "unknown mode"}})
+			return
+		}
+		datum := make(map[string][]string)
+		for k, v := range submission.Answers {
+			datum[k] = v
+		}
+		datum[internal.SessionKey] = []string{sess}
+		if errs := ctx.submit(req, mode, sess, datum); len(errs) > 0 {
+			writeErrors(resp, errs)
+			return
+		}
+		writeJSON(resp, http.StatusOK, &internal.APISession{Session: sess, Answers: internal.NewAPIAnswers(datum)})
+	default:
+		resp.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// writeErrors responds with submission errors (a closed survey is forbidden rather than a bad request)
+func writeErrors(resp http.ResponseWriter, errs map[string]string) {
+	_, closed := errs[internal.ClosedKey]
+	status := http.StatusBadRequest
+	if closed {
+		status = http.StatusForbidden
+	}
+	writeJSON(resp, status, &internal.ValidationResult{Errors: errs, Closed: closed})
+}
+
+func writeJSON(resp http.ResponseWriter, status int, obj interface{}) {
+	datum, err := json.Marshal(obj)
+	if err != nil {
+		internal.Error("unable to marshal response", err)
+		resp.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	resp.Header().Set("Content-Type", "application/json")
+	resp.WriteHeader(status)
+	resp.Write(datum)
 }
 
-func getMasks() []string {
-	mask.Lock()
-	defer mask.Unlock()
-	var masks []string
-	for k, v := range clientIDs {
-		masks = append(masks, fmt.Sprintf("%s (%s)", k, v))
+// masksEndpoint exports the client masks (for authorized de-anonymization) as csv
+func masksEndpoint(resp http.ResponseWriter, req *http.Request, ctx *Context) {
+	if !adminLogin(resp, req, ctx) {
+		return
 	}
-	sort.Strings(masks)
-	return masks
+	if !ctx.masking {
+		resp.WriteHeader(http.StatusNotFound)
+		return
+	}
+	internal.Info("client masks exported")
+	resp.Header().Set("Content-Type", "text/csv")
+	resp.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s.csv\"", masksFileName))
+	w := csv.NewWriter(resp)
+	w.Write([]string{"mask", internal.ClientKey})
+	for _, e := range ctx.masks.Entries() {
+		w.Write([]string{e.Mask, e.Client})
+	}
+	w.Flush()
 }
 
 func adminEndpoint(resp http.ResponseWriter, req *http.Request, ctx *Context) {
@@ -340,103 +900,273 @@ func adminEndpoint(resp http.ResponseWriter, req *http.Request, ctx *Context) {
 		return
 	}
 	req.ParseForm()
-	restarting := false
+	switching := false
 	bundling := true
+	name := ""
+	schedule := ""
+	var invites []string
+	generate := 0
+	target := ctx
 	for k, v := range req.Form {
 		switch k {
 		case "questions":
-			name := v[0]
-			if name == qReset {
-				name = ctx.available[0]
-			}
-			q := filepath.Join(ctx.temp, questionFileName)
-			if err := ioutil.WriteFile(q, []byte(name), 0644); err != nil {
-				internal.Error("unable to write question file and restart", err)
+			name = v[0]
+		case "mount":
+			for _, m := range ctx.mounts {
+				if m.name == v[0] {
+					target = m
+				}
 			}
 		case "restart":
-			restarting = internal.IsChecked(v)
+			switching = internal.IsChecked(v)
 		case "bundling":
 			bundling = internal.IsChecked(v)
+		case "schedule":
+			schedule = v[0]
+		case "invites":
+			invites = strings.Fields(strings.Join(v, "\n"))
+		case "generate":
+			generate, _ = strconv.Atoi(v[0])
 		}
 	}
-	if restarting {
-		if bundling {
-			internal.Info("bundling")
-			bundle(ctx, "")
+	warning := ""
+	if schedule != "" {
+		if err := target.current().schedule.Override(schedule); err != nil {
+			warning = err.Error()
+		} else {
+			internal.Info(fmt.Sprintf("survey schedule: %s (tag %s)", schedule, target.current().tag))
+		}
+	}
+	if target.invites != nil && (len(invites) > 0 || generate > 0) {
+		added, err := target.invites.Add(invites)
+		if err == nil && generate > 0 {
+			var codes []string
+			codes, err = target.invites.Generate(generate)
+			added += len(codes)
+		}
+		if err != nil {
+			internal.Error("unable to add invitations", err)
+			warning = err.Error()
+		}
+		internal.Info(fmt.Sprintf("added %d invitations", added))
+	}
+	if switching && name != "" {
+		if name == qReset {
+			name = target.initial
+		}
+		if err := target.switchSet(name, bundling); err == nil {
+			// NOTE: the selected set is still used if the server is restarted
+			if err := ioutil.WriteFile(target.questionFile(), []byte(name), 0644); err != nil {
+				internal.Error("unable to write question file", err)
+			}
+		} else {
+			internal.Error("unable to switch question set", err)
+			warning = err.Error()
 		}
-		internal.Info("restart requested")
-		os.Exit(1)
 	}
 	lock.Lock()
 	defer lock.Unlock()
-	pd := &internal.ManifestData{}
+	pd := &internal.AdminData{}
+	pd.Title = "Admin"
+	pd.Warning = warning
 	pd.Available = ctx.available
 	pd.Available = append(pd.Available, qReset)
-	f, m, err := ctx.getManifest()
-	pd.Title = "Admin"
-	pd.Tag = ctx.tag
-	pd.File = f
-	pd.CfgName = ctx.cfgName
 	pd.ShowMasks = false
 	if ctx.masking {
 		pd.ShowMasks = ctx.showMask
 	}
-	if err == nil {
-		for i, obj := range m.Files {
-			entry := &internal.ManifestEntry{}
-			entry.Name = obj
-			entry.Client = m.Clients[i]
-			entry.Mode = m.Modes[i]
-			entry.Idx = i
-			if pd.ShowMasks {
-				mask.Lock()
-				for k, v := range clientIDs {
-					if v == entry.Client {
-						entry.Mask = k
-						break
-					}
-				}
-				mask.Unlock()
+	for _, m := range ctx.mounts {
+		pd.Surveys = append(pd.Surveys, m.manifestData(pd.ShowMasks))
+	}
+	if err := ctx.adminTmpl.Execute(resp, pd); err != nil {
+		internal.Error("template execution error", err)
+	}
+}
+
+func liveEndpoint(resp http.ResponseWriter, req *http.Request, ctx *Context) {
+	if !adminLogin(resp, req, ctx) {
+		return
+	}
+	pd := &internal.AdminData{}
+	pd.Title = "Live"
+	if err := ctx.liveTmpl.Execute(resp, pd); err != nil {
+		internal.Error("template execution error", err)
+	}
+}
+
+// liveEventsEndpoint streams (server-sent) events as results are recorded, starting with the current state
+func liveEventsEndpoint(resp http.ResponseWriter, req *http.Request, ctx *Context) {
+	if !adminLogin(resp, req, ctx) {
+		return
+	}
+	flusher, ok := resp.(http.Flusher)
+	if !ok {
+		resp.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	events := ctx.events.Subscribe()
+	defer ctx.events.Unsubscribe(events)
+	resp.Header().Set("Content-Type", "text/event-stream")
+	resp.Header().Set("Cache-Control", "no-cache")
+	resp.Header().Set("Connection", "keep-alive")
+	for _, m := range ctx.mounts {
+		set := m.current()
+		datum, err := json.Marshal(set.live.Event(m.name, set.tag))
+		if err != nil {
+			internal.Error("unable to write event", err)
+			return
+		}
+		fmt.Fprintf(resp, "data: %s\n\n", datum)
+	}
+	flusher.Flush()
+	keepAlive := time.NewTicker(liveKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-req.Context().Done():
+			return
+		case <-keepAlive.C:
+			fmt.Fprint(resp, ": keep-alive\n\n")
+		case datum, ok := <-events:
+			if !ok {
+				return
 			}
-			pd.Manifest = append(pd.Manifest, entry)
+			fmt.Fprintf(resp, "data: %s\n\n", datum)
 		}
-	} else {
+		flusher.Flush()
+	}
+}
+
+func (ctx *Context) manifestData(showMasks bool) *internal.ManifestData {
+	set := ctx.current()
+	pd := &internal.ManifestData{}
+	f, m, err := set.getManifest()
+	pd.Name = ctx.name
+	pd.Base = ctx.base
+	pd.Title = set.title
+	pd.Tag = set.tag
+	pd.File = f
+	pd.CfgName = set.cfgName
+	pd.ShowMasks = showMasks
+	if err != nil {
 		pd.Warning = err.Error()
+		return pd
 	}
-	if err := ctx.adminTmpl.Execute(resp, pd); err != nil {
-		internal.Error("template execution error", err)
+	if ctx.invites != nil {
+		pd.Invite = true
+		for _, code := range ctx.invites.Codes() {
+			status := m.Status(code)
+			if ctx.invites.Completed(code) {
+				status = "completed"
+			}
+			pd.Invites = append(pd.Invites, &internal.InviteEntry{Code: code, Status: status})
+		}
 	}
+	pd.Window = set.schedule.Window()
+	pd.Schedule = set.schedule.State()
+	pd.MaxResponses = set.maxResponses
+	pd.Responses = m.Completed("")
+	for _, q := range set.quotas {
+		pd.Quotas = append(pd.Quotas, q.Counted(set.live, ""))
+	}
+	for i, obj := range m.Files {
+		entry := &internal.ManifestEntry{}
+		entry.Name = obj
+		entry.Client = m.Clients[i]
+		entry.Session = m.Sessions[i]
+		entry.Mode = m.Modes[i]
+		entry.Idx = i
+		if history := m.History[i]; len(history) > 0 {
+			entry.Results = len(history)
+			entry.Started = history[0].Timestamp
+			entry.Updated = history[len(history)-1].Timestamp
+		}
+		if showMasks {
+			entry.Mask, _ = ctx.masks.Client(entry.Client)
+		}
+		pd.Manifest = append(pd.Manifest, entry)
+	}
+	return pd
+}
+
+// questionFile is where the selected question set is kept (across restarts)
+func (ctx *Context) questionFile() string {
+	if ctx.name == "" {
+		return filepath.Join(ctx.temp, questionFileName)
+	}
+	return filepath.Join(ctx.temp, fmt.Sprintf("%s.%s", questionFileName, ctx.name))
 }
 
-func bundle(ctx *Context, readResult string) []byte {
+// inviteFile is where the invitation codes of a survey are kept
+func (ctx *Context) inviteFile() string {
+	if ctx.name == "" {
+		return filepath.Join(ctx.storage, inviteFileName)
+	}
+	return filepath.Join(ctx.storage, fmt.Sprintf("%s.%s", inviteFileName, ctx.name))
+}
+
+func (ctx *Context) loadInvites() {
+	invites, err := internal.LoadInvites(ctx.inviteFile())
+	if err != nil {
+		internal.Fatal("unable to read invitations", err)
+	}
+	ctx.invites = invites
+	internal.Info(fmt.Sprintf("invitation only: %s (%d codes)", ctx.inviteFile(), len(invites.Codes())))
+}
+
+func (ctx *Context) inputs(set *surveySet) (internal.Inputs, error) {
+	f, _, err := set.getManifest()
+	if err != nil {
+		return internal.Inputs{}, err
+	}
+	results := fmt.Sprintf("survey.%s", internal.TimeString())
+	if ctx.name != "" {
+		results = fmt.Sprintf("survey.%s.%s", ctx.name, internal.TimeString())
+	}
+	return internal.Inputs{
+		Manifest:  f,
+		OutName:   filepath.Join(ctx.temp, results),
+		Directory: set.store,
+		Config:    set.memoryConfig,
+		Store:     set.results,
+	}, nil
+}
+
+func bundle(ctx *Context, set *surveySet) {
 	lock.Lock()
 	defer lock.Unlock()
-	f, _, err := ctx.getManifest()
+	inputs, err := ctx.inputs(set)
 	if err != nil {
 		internal.Error("unable to read bundle manifest", err)
-		return nil
-	}
-	results := filepath.Join(ctx.temp, fmt.Sprintf("survey.%s", internal.TimeString()))
-	internal.Info(fmt.Sprintf("result file: %s", results))
-	inputs := internal.Inputs{
-		Manifest:  f,
-		OutName:   results,
-		Directory: ctx.store,
-		Config:    ctx.memoryConfig,
+		return
 	}
+	internal.Info(fmt.Sprintf("result file: %s", inputs.OutName))
 	if err := inputs.Process(); err != nil {
 		internal.Error("unable to process results", err)
-		return nil
 	}
-	if len(readResult) > 0 {
-		data, err := ioutil.ReadFile(fmt.Sprintf("%s.%s", results, readResult))
-		if err != nil {
-			internal.Error("unable to read result file", err)
-			return nil
-		}
-		return data
+}
+
+// archive writes the bundled results (and inputs) into the storage of a set
+func archive(ctx *Context, set *surveySet) {
+	lock.Lock()
+	defer lock.Unlock()
+	inputs, err := ctx.inputs(set)
+	if err != nil {
+		internal.Error("unable to read bundle manifest", err)
+		return
 	}
-	return nil
+	out := filepath.Join(set.store, fmt.Sprintf("%s.tar.gz", filepath.Base(inputs.OutName)))
+	f, err := os.Create(out)
+	if err != nil {
+		internal.Error("unable to create archive", err)
+		return
+	}
+	defer f.Close()
+	if err := inputs.Bundle(f); err != nil {
+		internal.Error("unable to archive results", err)
+		return
+	}
+	internal.Info(fmt.Sprintf("archived results: %s", out))
 }
 
 func adminLogin(resp http.ResponseWriter, req *http.Request, ctx *Context) bool {
@@ -452,24 +1182,46 @@ func adminLogin(resp http.ResponseWriter, req *http.Request, ctx *Context) bool
 	return true
 }
 
-func getResults(resp http.ResponseWriter, req *http.Request, ctx *Context, display bool) {
+func getResults(resp http.ResponseWriter, req *http.Request, ctx *Context, display string) {
 	if !adminLogin(resp, req, ctx) {
 		return
 	}
-	fileResult := "tar.gz"
-	if display {
-		fileResult = "html"
+	name, b, err := ctx.results(req, display)
+	if err != nil {
+		internal.Error("unable to process results", err)
+		resp.WriteHeader(http.StatusInternalServerError)
+		resp.Write([]byte(fmt.Sprintf("unable to process results: %v", err)))
+		return
+	}
+	if display == "" {
+		resp.Header().Set("Content-Type", "application/gzip")
+		resp.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s.tar.gz\"", name))
+	}
+	resp.Write(b.Bytes())
+}
+
+// results stitches (and buffers) the results of the active set under the lock, so (slow) clients never hold it
+func (ctx *Context) results(req *http.Request, display string) (string, *bytes.Buffer, error) {
+	lock.Lock()
+	defer lock.Unlock()
+	inputs, err := ctx.inputs(ctx.current())
+	if err != nil {
+		return "", nil, err
 	}
-	data := bundle(ctx, fileResult)
-	if data == nil {
-		resp.Write([]byte("unable to process results"))
+	inputs.Orphans = req.URL.Query().Get("orphans") == "true"
+	b := &bytes.Buffer{}
+	if display != "" {
+		err = inputs.Render(b, display)
 	} else {
-		resp.Write(data)
+		err = inputs.Bundle(b)
 	}
+	return filepath.Base(inputs.OutName), b, err
 }
 
 func (ctx *Context) newPage(req *http.Request) *internal.PageData {
-	return internal.NewPageData(req, ctx.snapshot)
+	pd := internal.NewPageData(req, ctx.snapshot)
+	pd.Base = ctx.base
+	return pd
 }
 
 func surveyEndpoint(resp http.ResponseWriter, req *http.Request, ctx *Context) {
@@ -477,11 +1229,76 @@ func surveyEndpoint(resp http.ResponseWriter, req *http.Request, ctx *Context) {
 	if !valid {
 		return
 	}
+	if code := internal.NormalizeInvite(sess); ctx.invites != nil && code != sess {
+		// NOTE: results are kept by the issued (lowercase) code, not the code as typed
+		target := ctx.base + surveyURL + code
+		if req.URL.RawQuery != "" {
+			target += "?" + req.URL.RawQuery
+		}
+		http.Redirect(resp, req, target, http.StatusFound)
+		return
+	}
+	if set := ctx.current(); !set.schedule.Open(time.Now()) && !ctx.started(sess) {
+		ctx.closedPage(resp, req, set.closedMessage())
+		return
+	}
+	if ctx.invites != nil {
+		if msg := ctx.invitation(sess); msg != "" {
+			resp.WriteHeader(http.StatusForbidden)
+			ctx.closedPage(resp, req, msg)
+			return
+		}
+	}
+	if ctx.cookieSession(req) != sess {
+		// NOTE: only sessions not yet started (or invitation codes) can be bound to a new browser
+		if ctx.invites == nil && ctx.started(sess) {
+			resp.WriteHeader(http.StatusForbidden)
+			ctx.closedPage(resp, req, sessionForeign)
+			return
+		}
+		ctx.bindSession(resp, sess)
+	}
+	ctx.identity.Bind(resp, req)
+	set := ctx.forSession(sess)
 	pd := ctx.newPage(req)
 	pd.Session = sess
+	pd.Page = 1
+	pd.Pages = set.pages
+	var state map[string][]string
+	if set.pages > 1 {
+		existing, err := set.results.LoadSession(sess)
+		if err != nil {
+			internal.Error("unable to read session state", err)
+		}
+		if existing != nil {
+			state = existing.Datum
+		}
+		parts := strings.Split(req.URL.Path, "/")
+		if len(parts) > 3 && parts[3] != "" {
+			p, err := strconv.Atoi(parts[3])
+			if err != nil || p < 1 || p > set.pages {
+				resp.WriteHeader(http.StatusNotFound)
+				return
+			}
+			pd.Page = p
+		} else {
+			if p, ok := state[internal.PageKey]; ok && len(p) > 0 {
+				if last, err := strconv.Atoi(p[0]); err == nil && last >= 1 && last <= set.pages {
+					http.Redirect(resp, req, ctx.base+fmt.Sprintf(surveyPageURL, sess, last, pd.QueryParams), http.StatusFound)
+					return
+				}
+			}
+		}
+	}
 	query := req.URL.Query()
-	for _, q := range ctx.questions {
+	for _, q := range internal.Present(set.questions, sess) {
 		obj := q
+		if obj.PageBreak {
+			continue
+		}
+		obj.Fill(state[obj.Key])
+		// NOTE: single page surveys (without state) are piped client-side
+		obj.Render(state)
 		value, ok := query[q.Text]
 		if ok && len(value) == 1 {
 			obj.Value = value[0]
@@ -489,20 +1306,56 @@ func surveyEndpoint(resp http.ResponseWriter, req *http.Request, ctx *Context) {
 		if obj.Hidden() {
 			pd.Hidden = append(pd.Hidden, obj)
 		} else {
-			pd.Questions = append(pd.Questions, obj)
+			if obj.Page == pd.Page {
+				pd.Questions = append(pd.Questions, obj)
+			} else {
+				for _, v := range state[obj.Key] {
+					pd.Carried = append(pd.Carried, internal.CarriedValue{Key: obj.Key, Value: v})
+				}
+			}
 		}
 	}
-	pd.Title = ctx.title
+	pd.Title = set.title
 	pd.HandleTemplate(resp, ctx.surveyTmpl)
 }
 
+// lint checks question definitions, returning the exit code
+func lint(args []string) int {
+	set := flag.NewFlagSet("lint", flag.ExitOnError)
+	resources := set.String("resources", "", "static resource directory (to check media files)")
+	set.Parse(args)
+	if set.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "usage: interrogate lint [--resources <dir>] <file.yaml>...")
+		return 2
+	}
+	code := 0
+	for _, file := range set.Args() {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", file, err)
+			code = 1
+			continue
+		}
+		for _, p := range internal.Lint(data, *resources) {
+			if p.Line > 0 {
+				fmt.Printf("%s:%d: %s\n", file, p.Line, p.Message)
+			} else {
+				fmt.Printf("%s: %s\n", file, p.Message)
+			}
+			code = 1
+		}
+	}
+	return code
+}
+
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "lint" {
+		os.Exit(lint(os.Args[2:]))
+	}
 	bind := flag.String("bind", "0.0.0.0:8080", "binding (ip:port)")
 	tag := flag.String("tag", internal.TimeString(), "output tag")
 	configFile := flag.String("config", "settings.conf", "configuration path")
 	flag.Parse()
-	clientIDs = make(map[string]string)
-	knownIDs = make(map[string]string)
 	cfg := *configFile
 	conf := &internal.Configuration{}
 	cfgData, err := ioutil.ReadFile(cfg)
@@ -531,12 +1384,11 @@ func main() {
 		internal.Fatal("no question set?", nil)
 	}
 	dir := filepath.Dir(cfg)
-	settingsFile := filepath.Join(dir, questions)
 	runSurvey(conf, &initSurvey{
 		bind:        *bind,
 		tag:         *tag,
 		tmp:         tmp,
-		questions:   settingsFile,
+		questions:   questions,
 		inQuestions: initialQuestions,
 		searchDir:   dir,
 		cwd:         cwd,
@@ -560,22 +1412,28 @@ func runSurvey(conf *internal.Configuration, settings *initSurvey) {
 		internal.Fatal("unable to parse base template", err)
 	}
 	snapValue := conf.Server.Snapshot
-	ctx := &Context{}
+	ctx := &Context{server: &server{}}
 	ctx.snapshot = snapValue
-	ctx.tag = internal.SetIfEmpty(conf.Server.Tag, settings.tag)
-	ctx.store = settings.resolvePath(conf.Server.Storage)
-	ctx.store = filepath.Join(ctx.store, ctx.tag)
+	ctx.fixedTag = conf.Server.Tag
+	ctx.initial = settings.inQuestions
+	ctx.storage = settings.resolvePath(conf.Server.Storage)
+	ctx.storeBackend = conf.Storage.Backend
+	ctx.indexBackend = conf.Server.Index
+	ctx.searchDir = settings.searchDir
+	ctx.sessions = make(map[string]*boundSession)
 	ctx.temp = settings.tmp
 	ctx.staticPath = staticURL
 	ctx.serveStatic = settings.resolvePath(conf.Server.Resources)
 	ctx.beginTmpl = internal.ReadTemplate(baseTemplate, "begin")
 	ctx.surveyTmpl = internal.ReadTemplate(baseTemplate, "survey")
 	ctx.completeTmpl = internal.ReadTemplate(baseTemplate, "complete")
+	ctx.closedTmpl = internal.ReadTemplate(baseTemplate, "closed")
 	ctx.adminTmpl = internal.ReadTemplate(baseTemplate, "admin")
+	ctx.liveTmpl = internal.ReadTemplate(baseTemplate, "live")
+	ctx.events = internal.NewBroker()
 	ctx.adminUser = internal.SetIfEmpty(conf.Server.Admin.User, "admin")
 	ctx.adminPass = internal.SetIfEmpty(conf.Server.Admin.Pass, time.Now().Format("150405"))
 	ctx.available = []string{settings.inQuestions}
-	ctx.cfgName = settings.questions
 	ctx.anonymous = false
 	switch conf.Server.Clients {
 	case internal.ClientMaskMode:
@@ -588,6 +1446,13 @@ func runSurvey(conf *internal.Configuration, settings *initSurvey) {
 		ctx.masking = true
 		ctx.showMask = false
 		ctx.anonymous = true
+	case internal.ClientHMACMode:
+		ctx.masking = false
+		ctx.showMask = false
+		if conf.Server.PseudonymKey == "" {
+			internal.Fatal("the hmac client mode requires a pseudonymkey", nil)
+		}
+		ctx.pseudonymKey = conf.Server.PseudonymKey
 	default:
 		internal.Fatal(fmt.Sprintf("unknown client ip handling mode: %s", conf.Server.Clients), nil)
 	}
@@ -605,39 +1470,142 @@ func runSurvey(conf *internal.Configuration, settings *initSurvey) {
 		}
 	}
 	internal.Info(fmt.Sprintf("admin login: %s (user)  %s (password)", ctx.adminUser, ctx.adminPass))
-	for _, d := range []string{ctx.store, ctx.temp} {
+	for _, d := range []string{ctx.storage, ctx.temp} {
 		if err := os.MkdirAll(d, 0755); err != nil {
 			internal.Fatal("unable to create directory", err)
 		}
 	}
-	if err := ctx.newSet(fmt.Sprintf("%s%s", settings.questions, internal.ConfigExt)); err != nil {
+	signer, err := internal.LoadSigner(filepath.Join(ctx.storage, signerFileName))
+	if err != nil {
+		internal.Fatal("unable to load session key", err)
+	}
+	ctx.signer = signer
+	identity, err := internal.NewIdentity(conf.Server.Identity, conf.Server.Proxies, signer)
+	if err != nil {
+		internal.Fatal("invalid client identity", err)
+	}
+	ctx.identity = identity
+	masks, err := internal.LoadMasks(filepath.Join(ctx.storage, masksFileName), conf.Server.MaskKey)
+	if err != nil {
+		internal.Fatal("unable to load client masks", err)
+	}
+	if ctx.masking && !masks.Persisted() {
+		internal.Info("client masks are not kept across restarts (no maskkey configured)")
+	}
+	ctx.masks = masks
+	set, err := ctx.loadSet(settings.questions, internal.SetIfEmpty(conf.Server.Tag, settings.tag))
+	if err != nil {
 		internal.Fatal("unable to load question set", err)
 	}
-	http.HandleFunc("/", func(resp http.ResponseWriter, req *http.Request) {
+	if conf.Server.Invites {
+		ctx.loadInvites()
+	}
+	ctx.active = set
+	ctx.mounts = append(ctx.mounts, ctx)
+	var names []string
+	for name := range conf.Server.Mounts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	mux := ctx.routes()
+	for _, name := range names {
+		m := ctx.newMount(name, conf.Server.Mounts[name], internal.SetIfEmpty(conf.Server.Tag, settings.tag))
+		ctx.mounts = append(ctx.mounts, m)
+		mux.Handle(m.base+"/", http.StripPrefix(m.base, m.routes()))
+		internal.Info(fmt.Sprintf("mounted %s at %s/", m.name, m.base))
+	}
+	mux.HandleFunc("/admin", func(resp http.ResponseWriter, req *http.Request) {
+		adminEndpoint(resp, req, ctx)
+	})
+	mux.HandleFunc("/admin/masks", func(resp http.ResponseWriter, req *http.Request) {
+		masksEndpoint(resp, req, ctx)
+	})
+	mux.HandleFunc("/admin/live", func(resp http.ResponseWriter, req *http.Request) {
+		liveEndpoint(resp, req, ctx)
+	})
+	mux.HandleFunc("/admin/live/events", func(resp http.ResponseWriter, req *http.Request) {
+		liveEventsEndpoint(resp, req, ctx)
+	})
+	mux.Handle(staticURL, http.StripPrefix(staticURL, ctx))
+	if err := http.ListenAndServe(internal.SetIfEmpty(conf.Server.Bind, settings.bind), mux); err != nil {
+		internal.Fatal("unable to start", err)
+	}
+}
+
+// newMount creates a survey served under /s/<name>/ with its own tag, storage and results
+func (ctx *Context) newMount(name string, cfg internal.MountConfig, tag string) *Context {
+	known := false
+	for _, a := range ctx.available {
+		if a == name {
+			known = true
+		}
+	}
+	if !known {
+		internal.Fatal(fmt.Sprintf("unknown question set to mount: %s", name), nil)
+	}
+	m := &Context{server: ctx.server}
+	m.name = name
+	m.base = fmt.Sprintf("%s%s", mountURL, name)
+	m.initial = name
+	m.snapshot = ctx.snapshot
+	if cfg.Snapshot != nil {
+		m.snapshot = *cfg.Snapshot
+	}
+	m.fixedTag = internal.SetIfEmpty(cfg.Tag, name)
+	m.sessions = make(map[string]*boundSession)
+	questions := name
+	if internal.PathExists(m.questionFile()) {
+		q, err := ioutil.ReadFile(m.questionFile())
+		if err != nil {
+			internal.Fatal("unable to read question settings file", err)
+		}
+		questions = internal.SetIfEmpty(string(q), name)
+	}
+	set, err := m.loadSet(questions, internal.SetIfEmpty(cfg.Tag, fmt.Sprintf("%s-%s", name, tag)))
+	if err != nil {
+		internal.Fatal(fmt.Sprintf("unable to load mounted question set %s", name), err)
+	}
+	m.active = set
+	if cfg.Invites {
+		m.loadInvites()
+	}
+	return m
+}
+
+// routes are the survey (participant and results) endpoints of a survey
+func (ctx *Context) routes() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(resp http.ResponseWriter, req *http.Request) {
 		homeEndpoint(resp, req, ctx)
 	})
-	http.HandleFunc(surveyURL, func(resp http.ResponseWriter, req *http.Request) {
+	mux.HandleFunc(surveyURL, func(resp http.ResponseWriter, req *http.Request) {
 		surveyEndpoint(resp, req, ctx)
 	})
-	http.HandleFunc("/completed", func(resp http.ResponseWriter, req *http.Request) {
+	mux.HandleFunc("/completed", func(resp http.ResponseWriter, req *http.Request) {
 		completeEndpoint(resp, req, ctx)
 	})
-	http.HandleFunc("/results", func(resp http.ResponseWriter, req *http.Request) {
-		getResults(resp, req, ctx, true)
+	mux.HandleFunc("/closed", func(resp http.ResponseWriter, req *http.Request) {
+		closedEndpoint(resp, req, ctx)
 	})
-	http.HandleFunc("/bundle.tar.gz", func(resp http.ResponseWriter, req *http.Request) {
-		getResults(resp, req, ctx, false)
+	mux.HandleFunc("/results", func(resp http.ResponseWriter, req *http.Request) {
+		getResults(resp, req, ctx, internal.ResultsHTML)
 	})
-	http.HandleFunc("/admin", func(resp http.ResponseWriter, req *http.Request) {
-		adminEndpoint(resp, req, ctx)
+	mux.HandleFunc("/results/summary", func(resp http.ResponseWriter, req *http.Request) {
+		getResults(resp, req, ctx, internal.SummaryHTML)
+	})
+	mux.HandleFunc("/bundle.tar.gz", func(resp http.ResponseWriter, req *http.Request) {
+		getResults(resp, req, ctx, "")
+	})
+	mux.HandleFunc(apiURL+"survey", func(resp http.ResponseWriter, req *http.Request) {
+		apiSurveyEndpoint(resp, req, ctx)
 	})
-	for _, v := range []string{saveFileName, "snapshot"} {
-		http.HandleFunc(fmt.Sprintf("/%s/", v), func(resp http.ResponseWriter, req *http.Request) {
+	mux.HandleFunc(apiURL+"sessions/", func(resp http.ResponseWriter, req *http.Request) {
+		apiSessionEndpoint(resp, req, ctx)
+	})
+	for _, v := range []string{saveFileName, snapshotMode} {
+		mux.HandleFunc(fmt.Sprintf("/%s/", v), func(resp http.ResponseWriter, req *http.Request) {
 			saveEndpoint(resp, req, ctx)
 		})
 	}
-	http.Handle(staticURL, http.StripPrefix(staticURL, ctx))
-	if err := http.ListenAndServe(internal.SetIfEmpty(conf.Server.Bind, settings.bind), nil); err != nil {
-		internal.Fatal("unable to start", err)
-	}
+	return mux
 }
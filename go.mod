@@ -2,4 +2,7 @@ module voidedtech.com/interrogate
 
 go 1.14
 
-require gopkg.in/yaml.v2 v2.4.0 // indirect
+require (
+	github.com/mattn/go-sqlite3 v1.14.22
+	gopkg.in/yaml.v2 v2.4.0
+)
@@ -0,0 +1,79 @@
+package internal
+
+type (
+	// APISurvey is the JSON definition of the active survey
+	APISurvey struct {
+		Title  string     `json:"title"`
+		Tag    string     `json:"tag"`
+		Pages  int        `json:"pages"`
+		Fields []APIField `json:"fields"`
+	}
+
+	// APIField is the JSON definition of a survey field
+	APIField struct {
+		ID          int          `json:"id"`
+		Key         string       `json:"key"`
+		Type        string       `json:"type"`
+		Text        string       `json:"text"`
+		Description string       `json:"desc"`
+		Options     []string     `json:"options,omitempty"`
+		Basis       string       `json:"basis,omitempty"`
+		Required    bool         `json:"required"`
+		Page        int          `json:"page"`
+		Group       string       `json:"group,omitempty"`
+		Randomize   bool         `json:"randomize,omitempty"`
+		RandomGroup bool         `json:"random_group,omitempty"`
+		Conditions  []*Condition `json:"conditions,omitempty"`
+	}
+
+	// APISubmission is a JSON submission for a session
+	APISubmission struct {
+		Mode    string              `json:"mode"`
+		Answers map[string][]string `json:"answers"`
+	}
+
+	// APISession is the JSON state of a session
+	APISession struct {
+		Session string              `json:"session"`
+		Token   string              `json:"token,omitempty"`
+		Answers map[string][]string `json:"answers,omitempty"`
+	}
+)
+
+// NewAPIAnswers copies answers for an api response (without the client)
+func NewAPIAnswers(datum map[string][]string) map[string][]string {
+	answers := make(map[string][]string)
+	for k, v := range datum {
+		if k != ClientKey {
+			answers[k] = v
+		}
+	}
+	return answers
+}
+
+// NewAPISurvey converts a question set into its JSON definition
+func NewAPISurvey(title, tag string, pages int, fields []Field) *APISurvey {
+	survey := &APISurvey{
+		Title: title,
+		Tag:   tag,
+		Pages: pages,
+	}
+	for _, f := range fields {
+		survey.Fields = append(survey.Fields, APIField{
+			ID:          f.ID,
+			Key:         f.Key,
+			Type:        f.Type,
+			Text:        f.Text,
+			Description: f.Description,
+			Options:     f.Options,
+			Basis:       f.Basis,
+			Required:    f.Required != "",
+			Page:        f.Page,
+			Group:       f.Group,
+			Randomize:   f.Randomize,
+			RandomGroup: f.RandomGroup != "",
+			Conditions:  f.Conditions(),
+		})
+	}
+	return survey
+}
@@ -0,0 +1,139 @@
+package internal
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	// RunConfigPrefix is the file prefix of the (exported) run configuration of a question set
+	RunConfigPrefix = "run.config."
+	autoOutPrefix   = "stitched"
+)
+
+type (
+	autoConfig struct {
+		file  string
+		stamp string
+	}
+)
+
+// RunConfigName gets the (timestamped) file name of a new run configuration for a tag
+func RunConfigName(tag string) string {
+	return fmt.Sprintf("%s%s.%s", RunConfigPrefix, tag, TimeString())
+}
+
+// AutoInputs discovers the indexes and run configs in a directory, creating inputs for each
+// index and run config generation of its tag (a run config applies until the next one was written)
+func AutoInputs(dir, out string) ([]Inputs, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	configs := make(map[string][]autoConfig)
+	found := false
+	indexes := make(map[string]string)
+	for _, f := range files {
+		if f.IsDir() {
+			continue
+		}
+		name := f.Name()
+		if strings.HasPrefix(name, RunConfigPrefix) {
+			// NOTE: older run configs have no tag (run.config.<timestamp>) and apply to every index
+			tag := ""
+			stamp := strings.TrimPrefix(name, RunConfigPrefix)
+			if idx := strings.LastIndex(stamp, "."); idx >= 0 {
+				tag = stamp[0:idx]
+				stamp = stamp[idx+1:]
+			}
+			configs[tag] = append(configs[tag], autoConfig{file: name, stamp: stamp})
+			found = true
+			continue
+		}
+		// NOTE: in order of precedence, the log imports a legacy manifest
+		for _, ext := range []string{indexLogExt, sqliteExt, manifestExt} {
+			if !strings.HasSuffix(name, ext) {
+				continue
+			}
+			tag := strings.TrimSuffix(name, ext)
+			if existing, ok := indexes[tag]; ok && autoRank(existing) <= autoRank(name) {
+				break
+			}
+			indexes[tag] = name
+			break
+		}
+	}
+	if len(indexes) == 0 {
+		return nil, fmt.Errorf("no index found in %s", dir)
+	}
+	if !found {
+		return nil, fmt.Errorf("no run config found in %s", dir)
+	}
+	var tags []string
+	for tag := range indexes {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	prefix := SetIfEmpty(out, filepath.Join(dir, autoOutPrefix))
+	var inputs []Inputs
+	for _, tag := range tags {
+		generations := append(append([]autoConfig{}, configs[tag]...), configs[""]...)
+		if len(generations) == 0 {
+			Info(fmt.Sprintf("no run config found for tag %s", tag))
+			continue
+		}
+		// NOTE: run configs are suffixed by a (sortable) timestamp
+		sort.Slice(generations, func(i, j int) bool {
+			return generations[i].stamp < generations[j].stamp
+		})
+		for idx, cfg := range generations {
+			in := Inputs{
+				Manifest:  filepath.Join(dir, indexes[tag]),
+				Config:    filepath.Join(dir, cfg.file),
+				Directory: dir,
+				OutName:   fmt.Sprintf("%s.%s.%s", prefix, tag, cfg.stamp),
+			}
+			if idx > 0 {
+				in.From = cfg.stamp
+			}
+			if idx < len(generations)-1 {
+				in.Until = generations[idx+1].stamp
+			}
+			inputs = append(inputs, in)
+		}
+	}
+	if len(inputs) == 0 {
+		return nil, fmt.Errorf("no run config found for the indexes in %s", dir)
+	}
+	return inputs, nil
+}
+
+func autoRank(name string) int {
+	for idx, ext := range []string{indexLogExt, sqliteExt, manifestExt} {
+		if strings.HasSuffix(name, ext) {
+			return idx
+		}
+	}
+	return -1
+}
+
+// within indicates if a result was submitted in the input's run config generation
+func (i Inputs) within(stored *StoredResult) bool {
+	if i.From == "" && i.Until == "" {
+		return true
+	}
+	ts := ""
+	if t, ok := stored.Data.Datum[TimestampKey]; ok && len(t) > 0 {
+		ts = t[0]
+	}
+	if i.From != "" && ts < i.From {
+		return false
+	}
+	if i.Until != "" && ts >= i.Until {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,110 @@
+package internal
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAutoInputs(t *testing.T) {
+	tests := []struct {
+		name   string
+		files  []string
+		inputs []string
+		err    string
+	}{
+		{"tagged", []string{"a.index.log", "b.index.log", "run.config.a.2020-01-01T00-00-00", "run.config.b.2020-01-02T00-00-00"}, []string{
+			"a.index.log run.config.a.2020-01-01T00-00-00 [,)",
+			"b.index.log run.config.b.2020-01-02T00-00-00 [,)",
+		}, ""},
+		{"generations", []string{"a.index.log", "run.config.a.2020-01-01T00-00-00", "run.config.a.2020-01-03T00-00-00", "run.config.a.2020-01-02T00-00-00"}, []string{
+			"a.index.log run.config.a.2020-01-01T00-00-00 [,2020-01-02T00-00-00)",
+			"a.index.log run.config.a.2020-01-02T00-00-00 [2020-01-02T00-00-00,2020-01-03T00-00-00)",
+			"a.index.log run.config.a.2020-01-03T00-00-00 [2020-01-03T00-00-00,)",
+		}, ""},
+		{"untagged", []string{"a.index.log", "b.index.manifest", "run.config.2020-01-01T00-00-00"}, []string{
+			"a.index.log run.config.2020-01-01T00-00-00 [,)",
+			"b.index.manifest run.config.2020-01-01T00-00-00 [,)",
+		}, ""},
+		{"untagged and tagged", []string{"a.index.log", "run.config.2020-01-01T00-00-00", "run.config.a.2020-01-02T00-00-00"}, []string{
+			"a.index.log run.config.2020-01-01T00-00-00 [,2020-01-02T00-00-00)",
+			"a.index.log run.config.a.2020-01-02T00-00-00 [2020-01-02T00-00-00,)",
+		}, ""},
+		{"log precedence", []string{"a.index.manifest", "a.index.log", "run.config.a.2020-01-01T00-00-00"}, []string{
+			"a.index.log run.config.a.2020-01-01T00-00-00 [,)",
+		}, ""},
+		{"other tag only", []string{"a.index.log", "b.index.log", "run.config.a.2020-01-01T00-00-00"}, []string{
+			"a.index.log run.config.a.2020-01-01T00-00-00 [,)",
+		}, ""},
+		{"no index", []string{"run.config.a.2020-01-01T00-00-00"}, nil, "no index found"},
+		{"no run config", []string{"a.index.log"}, nil, "no run config found"},
+		{"no matching run config", []string{"a.index.log", "run.config.b.2020-01-01T00-00-00"}, nil, "no run config found for the indexes"},
+	}
+	for _, test := range tests {
+		dir, err := ioutil.TempDir("", "auto")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+		for _, f := range test.files {
+			if err := ioutil.WriteFile(filepath.Join(dir, f), []byte{}, 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		inputs, err := AutoInputs(dir, "")
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%s: got error %v, want %s", test.name, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", test.name, err)
+			continue
+		}
+		var got []string
+		for _, in := range inputs {
+			if in.Directory != dir || !strings.HasPrefix(in.OutName, filepath.Join(dir, autoOutPrefix)) {
+				t.Errorf("%s: unexpected input %v", test.name, in)
+			}
+			got = append(got, filepath.Base(in.Manifest)+" "+filepath.Base(in.Config)+" ["+in.From+","+in.Until+")")
+		}
+		if strings.Join(got, "\n") != strings.Join(test.inputs, "\n") {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.inputs)
+		}
+	}
+}
+
+func TestWithin(t *testing.T) {
+	stored := func(ts string) *StoredResult {
+		datum := make(map[string][]string)
+		if ts != "" {
+			datum[TimestampKey] = []string{ts}
+		}
+		return &StoredResult{Data: &ResultData{Datum: datum}}
+	}
+	tests := []struct {
+		from   string
+		until  string
+		ts     string
+		expect bool
+	}{
+		{"", "", "2020-01-01T00-00-00", true},
+		{"", "", "", true},
+		{"2020-01-02T00-00-00", "", "2020-01-01T00-00-00", false},
+		{"2020-01-02T00-00-00", "", "2020-01-02T00-00-00", true},
+		{"", "2020-01-02T00-00-00", "2020-01-01T23-59-59", true},
+		{"", "2020-01-02T00-00-00", "2020-01-02T00-00-00", false},
+		{"2020-01-01T00-00-00", "2020-01-02T00-00-00", "2020-01-01T12-00-00", true},
+		{"2020-01-01T00-00-00", "2020-01-02T00-00-00", "", false},
+		{"", "2020-01-02T00-00-00", "", true},
+	}
+	for _, test := range tests {
+		in := Inputs{From: test.from, Until: test.until}
+		if got := in.within(stored(test.ts)); got != test.expect {
+			t.Errorf("[%s, %s) with '%s': got %v, want %v", test.from, test.until, test.ts, got, test.expect)
+		}
+	}
+}
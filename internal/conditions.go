@@ -0,0 +1,218 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	// CheckedOp indicates a clause is satisfied when the field has any value
+	CheckedOp = "checked"
+	clauseSep = " and "
+)
+
+var (
+	// NOTE: two character operators must be checked first
+	clauseOps = []string{"==", "!=", ">=", "<=", ">", "<"}
+)
+
+// ExplicitEnds indicates conditional sections are closed by 'end: true' (allowing nesting), otherwise the next conditional closes an open section
+func ExplicitEnds(questions []Question) bool {
+	for _, q := range questions {
+		if q.End {
+			return true
+		}
+	}
+	return false
+}
+
+// ClosesConditional indicates if a conditional question closes the innermost open section (of open sections)
+func (q Question) ClosesConditional(explicit bool, open int) bool {
+	if explicit {
+		return q.End
+	}
+	return open > 0
+}
+
+// ParseCondition parses an expression of the form '<id> <op> <value> [and ...]', values containing ' and ' (or starting with a quote) must be quoted ('...' or "...", with \ escaping the quote)
+func ParseCondition(expression string, negate bool) (*Condition, error) {
+	cond := &Condition{Negate: negate}
+	rest := strings.TrimSpace(expression)
+	for {
+		end := strings.IndexAny(rest, " \t=!<>")
+		if end < 0 {
+			end = len(rest)
+		}
+		id := rest[0:end]
+		rest = strings.TrimSpace(rest[end:])
+		op := ""
+		for _, o := range clauseOps {
+			if strings.HasPrefix(rest, o) {
+				op = o
+				break
+			}
+		}
+		if id == "" || op == "" {
+			return nil, fmt.Errorf("invalid condition '%s'", expression)
+		}
+		if !ValidKey(id) {
+			return nil, fmt.Errorf("invalid question reference '%s' in '%s'", id, expression)
+		}
+		value, remainder, err := conditionValue(strings.TrimSpace(rest[len(op):]))
+		if err != nil {
+			return nil, fmt.Errorf("%v in '%s'", err, expression)
+		}
+		cond.Clauses = append(cond.Clauses, Clause{ID: id, Op: op, Value: value})
+		if remainder == "" {
+			return cond, nil
+		}
+		rest = remainder
+	}
+}
+
+// conditionValue reads a (quoted) clause value, returning the value and the clauses after it
+func conditionValue(text string) (string, string, error) {
+	if text == "" || (text[0] != '"' && text[0] != '\'') {
+		idx := strings.Index(text, clauseSep)
+		if idx < 0 {
+			return strings.TrimSpace(text), "", nil
+		}
+		return strings.TrimSpace(text[0:idx]), strings.TrimSpace(text[idx+len(clauseSep):]), nil
+	}
+	quote := text[0]
+	var value strings.Builder
+	for idx := 1; idx < len(text); idx++ {
+		c := text[idx]
+		if c == '\\' && idx+1 < len(text) {
+			idx++
+			value.WriteByte(text[idx])
+			continue
+		}
+		if c != quote {
+			value.WriteByte(c)
+			continue
+		}
+		rest := text[idx+1:]
+		if strings.TrimSpace(rest) == "" {
+			return value.String(), "", nil
+		}
+		rest = " " + strings.TrimLeft(rest, " \t")
+		if !strings.HasPrefix(rest, clauseSep) {
+			return "", "", fmt.Errorf("unexpected '%s' after quoted value", strings.TrimSpace(rest))
+		}
+		return value.String(), strings.TrimSpace(rest[len(clauseSep):]), nil
+	}
+	return "", "", fmt.Errorf("unterminated quoted value")
+}
+
+func compareNumbers(op, value, expect string) bool {
+	v, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil {
+		return false
+	}
+	e, err := strconv.ParseFloat(strings.TrimSpace(expect), 64)
+	if err != nil {
+		return false
+	}
+	switch op {
+	case "==":
+		return v == e
+	case "!=":
+		return v != e
+	case ">":
+		return v > e
+	case ">=":
+		return v >= e
+	case "<":
+		return v < e
+	case "<=":
+		return v <= e
+	}
+	return false
+}
+
+func (c Clause) matches(values []string) bool {
+	var set []string
+	for _, v := range values {
+		if strings.TrimSpace(v) != "" {
+			set = append(set, v)
+		}
+	}
+	switch c.Op {
+	case CheckedOp:
+		return len(set) > 0
+	case "==":
+		for _, v := range set {
+			if v == c.Value || compareNumbers(c.Op, v, c.Value) {
+				return true
+			}
+		}
+		return false
+	case "!=":
+		for _, v := range set {
+			if v == c.Value || compareNumbers("==", v, c.Value) {
+				return false
+			}
+		}
+		return true
+	}
+	if len(set) == 0 {
+		return false
+	}
+	return compareNumbers(c.Op, set[0], c.Value)
+}
+
+// Evaluate checks a condition against a set of (visible) answers
+func (c *Condition) Evaluate(answers map[string][]string) bool {
+	result := true
+	for _, clause := range c.Clauses {
+		if !clause.matches(answers[clause.ID]) {
+			result = false
+			break
+		}
+	}
+	if c.Negate {
+		return !result
+	}
+	return result
+}
+
+// AddCondition attaches a display condition to a field
+func (f *Field) AddCondition(cond *Condition) {
+	f.conditions = append(f.conditions, cond)
+	datum, err := json.Marshal(f.conditions)
+	if err != nil {
+		Error("unable to serialize conditions", err)
+		return
+	}
+	f.ShowIf = string(datum)
+}
+
+// Conditions gets the display conditions of a field
+func (f *Field) Conditions() []*Condition {
+	return f.conditions
+}
+
+// Skipped determines which fields are hidden by logic for a set of answers
+func Skipped(fields []Field, answers map[string][]string) []string {
+	visible := make(map[string][]string)
+	var skipped []string
+	for _, f := range fields {
+		id := f.Key
+		show := true
+		for _, cond := range f.conditions {
+			if !cond.Evaluate(visible) {
+				show = false
+				break
+			}
+		}
+		if show {
+			visible[id] = answers[id]
+		} else {
+			skipped = append(skipped, id)
+		}
+	}
+	return skipped
+}
@@ -0,0 +1,158 @@
+package internal
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseCondition(t *testing.T) {
+	tests := []struct {
+		expression string
+		clauses    []Clause
+		err        string
+	}{
+		{"3 == Low", []Clause{{ID: "3", Op: "==", Value: "Low"}}, ""},
+		{"role != 'Student'", []Clause{{ID: "role", Op: "!=", Value: "Student"}}, ""},
+		{"age >= 18 and age < 65", []Clause{{ID: "age", Op: ">=", Value: "18"}, {ID: "age", Op: "<", Value: "65"}}, ""},
+		{`a<="5"`, []Clause{{ID: "a", Op: "<=", Value: "5"}}, ""},
+		{"a > 1", []Clause{{ID: "a", Op: ">", Value: "1"}}, ""},
+		{"no operator", nil, "invalid condition"},
+		{"== 5", nil, "invalid condition"},
+		{"a-b == 5", nil, "invalid question reference"},
+		{`a == "rock and roll" and b == x`, []Clause{{ID: "a", Op: "==", Value: "rock and roll"}, {ID: "b", Op: "==", Value: "x"}}, ""},
+		{"a == 'x > y' and b != '<=5'", []Clause{{ID: "a", Op: "==", Value: "x > y"}, {ID: "b", Op: "!=", Value: "<=5"}}, ""},
+		{"a == x=y", []Clause{{ID: "a", Op: "==", Value: "x=y"}}, ""},
+		{"a == O'Brien", []Clause{{ID: "a", Op: "==", Value: "O'Brien"}}, ""},
+		{`a == 'it\'s' and b == "say \"hi\""`, []Clause{{ID: "a", Op: "==", Value: "it's"}, {ID: "b", Op: "==", Value: `say "hi"`}}, ""},
+		{"a == ''", []Clause{{ID: "a", Op: "==", Value: ""}}, ""},
+		{"a == 'x", nil, "unterminated"},
+		{"a == 'x' b == y", nil, "after quoted value"},
+		{"a == x and b", nil, "invalid condition"},
+		{"a =< 5", nil, "invalid condition"},
+	}
+	for _, test := range tests {
+		cond, err := ParseCondition(test.expression, false)
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%s: got error %v, want %s", test.expression, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", test.expression, err)
+			continue
+		}
+		if len(cond.Clauses) != len(test.clauses) {
+			t.Errorf("%s: got %v, want %v", test.expression, cond.Clauses, test.clauses)
+			continue
+		}
+		for idx, c := range test.clauses {
+			if cond.Clauses[idx] != c {
+				t.Errorf("%s: got %v, want %v", test.expression, cond.Clauses[idx], c)
+			}
+		}
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	tests := []struct {
+		expression string
+		negate     bool
+		answers    map[string][]string
+		expect     bool
+	}{
+		{"a == Low", false, map[string][]string{"a": {"Low"}}, true},
+		{"a == Low", false, map[string][]string{"a": {"High"}}, false},
+		{"a == Low", false, map[string][]string{}, false},
+		{"a == Low", true, map[string][]string{"a": {"Low"}}, false},
+		{"a == 5", false, map[string][]string{"a": {"5.0"}}, true},
+		{"a == b", false, map[string][]string{"a": {"x", "b"}}, true},
+		{"a != Low", false, map[string][]string{"a": {"High"}}, true},
+		{"a != Low", false, map[string][]string{"a": {"Low"}}, false},
+		{"a != Low", false, map[string][]string{}, true},
+		{"a > 10", false, map[string][]string{"a": {"11"}}, true},
+		{"a > 10", false, map[string][]string{"a": {"9"}}, false},
+		{"a > 10", false, map[string][]string{"a": {"x"}}, false},
+		{"a > 10", false, map[string][]string{"a": {" "}}, false},
+		{"a <= 10", false, map[string][]string{"a": {"10"}}, true},
+		{"a >= 1 and b == y", false, map[string][]string{"a": {"2"}, "b": {"y"}}, true},
+		{"a >= 1 and b == y", false, map[string][]string{"a": {"2"}, "b": {"n"}}, false},
+	}
+	for _, test := range tests {
+		cond, err := ParseCondition(test.expression, test.negate)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", test.expression, err)
+			continue
+		}
+		if got := cond.Evaluate(test.answers); got != test.expect {
+			t.Errorf("%s (negate %v) with %v: got %v, want %v", test.expression, test.negate, test.answers, got, test.expect)
+		}
+	}
+	checked := &Condition{Clauses: []Clause{{ID: "c", Op: CheckedOp}}}
+	if !checked.Evaluate(map[string][]string{"c": {"on"}}) || checked.Evaluate(map[string][]string{}) {
+		t.Error("checked condition mismatch")
+	}
+}
+
+func TestSkipped(t *testing.T) {
+	condition := func(expression string, negate bool) *Condition {
+		cond, err := ParseCondition(expression, negate)
+		if err != nil {
+			t.Fatalf("%s: %v", expression, err)
+		}
+		return cond
+	}
+	newFields := func() []Field {
+		fields := []Field{{Key: "1"}, {Key: "2"}, {Key: "3"}, {Key: "4"}}
+		// 2 is only shown when 1 is low, 3 and 4 are skipped when 1 is none (skip_to), 4 depends on 2
+		fields[1].AddCondition(condition("1 == low", false))
+		fields[2].AddCondition(condition("1 == none", true))
+		fields[3].AddCondition(condition("1 == none", true))
+		fields[3].AddCondition(condition("2 == why", false))
+		return fields
+	}
+	tests := []struct {
+		name    string
+		answers map[string][]string
+		skipped []string
+	}{
+		{"shown", map[string][]string{"1": {"low"}, "2": {"why"}}, []string{}},
+		{"hidden", map[string][]string{"1": {"high"}, "2": {"why"}}, []string{"2", "4"}},
+		{"skip to", map[string][]string{"1": {"none"}}, []string{"2", "3", "4"}},
+		{"unanswered", map[string][]string{}, []string{"2", "4"}},
+	}
+	for _, test := range tests {
+		skipped := Skipped(newFields(), test.answers)
+		if strings.Join(skipped, ",") != strings.Join(test.skipped, ",") {
+			t.Errorf("%s: got %v, want %v", test.name, skipped, test.skipped)
+		}
+	}
+}
+
+func TestClosesConditional(t *testing.T) {
+	text := Question{Text: "open?", Type: "conditional"}
+	blank := Question{Type: "conditional"}
+	end := Question{Type: "conditional", End: true}
+	tests := []struct {
+		name      string
+		questions []Question
+		q         Question
+		open      int
+		expect    bool
+	}{
+		{"opens", []Question{text, blank}, text, 0, false},
+		{"next closes", []Question{text, text}, text, 1, true},
+		{"blank closes", []Question{text, blank}, blank, 1, true},
+		{"blank opens", []Question{blank}, blank, 0, false},
+		{"explicit nests", []Question{text, text, end, end}, text, 1, false},
+		{"explicit blank nests", []Question{text, blank, end, end}, blank, 1, false},
+		{"explicit end", []Question{text, end}, end, 1, true},
+		{"explicit end unopened", []Question{end}, end, 0, true},
+	}
+	for _, test := range tests {
+		explicit := ExplicitEnds(test.questions)
+		if got := test.q.ClosesConditional(explicit, test.open); got != test.expect {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.expect)
+		}
+	}
+}
@@ -1,21 +1,23 @@
 package internal
 
 import (
+	"crypto/rand"
 	"fmt"
 	"html/template"
-	"math/rand"
 	"net"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 )
 
 const (
 	// ConfigExt is the configuration file extension for questions
-	ConfigExt = ".yaml"
-	alphaNum  = "abcdefghijklmnopqrstuvwxyz0123456789"
+	ConfigExt  = ".yaml"
+	alphaNum   = "abcdefghijklmnopqrstuvwxyz0123456789"
+	timeFormat = "2006-01-02T15-04-05"
 )
 
 // PathExists checks if a file exists
@@ -42,22 +44,62 @@ func CreateHash(number int, value string) string {
 	return output
 }
 
+// ValidKey checks a question key is either numeric or an identifier (letter first, then letters, digits or '_')
+func ValidKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	if _, err := strconv.Atoi(key); err == nil {
+		return true
+	}
+	for idx, c := range key {
+		letter := (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+		if !letter && (idx == 0 || !((c >= '0' && c <= '9') || c == '_')) {
+			return false
+		}
+	}
+	switch key {
+	case SessionKey, ClientKey, TimestampKey, ModeKey, SkippedKey, PageKey, PresentedKey, ClosedKey, ParticipantKey:
+		return false
+	}
+	return true
+}
+
 // TimeString gets the current time as a YYYY-HH-MMTHH-MM-SS string
 func TimeString() string {
-	return time.Now().Format("2006-01-02T15-04-05")
+	return time.Now().Format(timeFormat)
 }
 
-// NewSession creates a new survey session (unique identifier)
+// NewSession creates a new survey session (unique, unpredictable identifier)
 func NewSession(length int) string {
-	alphaNumeric := []rune(alphaNum)
-	b := make([]rune, length)
-	runes := len(alphaNumeric)
-	for i := range b {
-		b[i] = alphaNumeric[rand.Intn(runes)]
+	// NOTE: bytes past the largest multiple of the alphabet size are redrawn so every character is equally likely
+	limit := byte(256 - 256%len(alphaNum))
+	b := make([]byte, 0, length)
+	buf := make([]byte, length)
+	for len(b) < length {
+		if _, err := rand.Read(buf); err != nil {
+			Fatal("unable to read random data", err)
+		}
+		for _, c := range buf {
+			if c < limit && len(b) < length {
+				b = append(b, alphaNum[int(c)%len(alphaNum)])
+			}
+		}
 	}
 	return string(b)
 }
 
+// CleanName restricts a name to lowercase alphanumerics (and underscores) for file naming
+func CleanName(name string) string {
+	output := ""
+	for _, c := range strings.ToLower(name) {
+		if (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') || (c == '_') {
+			output = output + string(c)
+		}
+	}
+	return output
+}
+
 // IsChecked will see if input form values indicate a checkbox is 'on'
 func IsChecked(values []string) bool {
 	for _, val := range values {
@@ -93,10 +135,6 @@ func SetIfEmpty(setting, defaultValue string) string {
 	return setting
 }
 
-func init() {
-	rand.Seed(time.Now().UnixNano())
-}
-
 // ReadAssetRaw reads an asset and returns the raw data
 func ReadAssetRaw(name string) ([]byte, error) {
 	fixed := name
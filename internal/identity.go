@@ -0,0 +1,141 @@
+package internal
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// IdentityRemote identifies clients by their connection (remote) address
+	IdentityRemote = "remote"
+	// IdentityProxy identifies clients by the forwarded address set by a trusted proxy
+	IdentityProxy = "proxy"
+	// IdentityCookie identifies clients by a long-lived (signed) device cookie
+	IdentityCookie = "cookie"
+	deviceCookie   = "interrogate_device"
+	devicePrefix   = "device-"
+	deviceLength   = 16
+	deviceLifetime = 365 * 24 * time.Hour
+)
+
+type (
+	// Identity determines which client (device or address) a request is from
+	Identity struct {
+		strategy string
+		proxies  []*net.IPNet
+		signer   *Signer
+	}
+)
+
+// NewIdentity creates a client identity strategy, proxies are the (CIDR) addresses trusted to forward client addresses
+func NewIdentity(strategy string, proxies []string, signer *Signer) (*Identity, error) {
+	i := &Identity{strategy: SetIfEmpty(strategy, IdentityRemote), signer: signer}
+	switch i.strategy {
+	case IdentityRemote, IdentityCookie:
+	case IdentityProxy:
+		if len(proxies) == 0 {
+			return nil, fmt.Errorf("the proxy identity requires trusted proxies")
+		}
+	default:
+		return nil, fmt.Errorf("unknown client identity: %s", strategy)
+	}
+	for _, p := range proxies {
+		if !strings.Contains(p, "/") {
+			if ip := net.ParseIP(p); ip != nil && ip.To4() != nil {
+				p = p + "/32"
+			} else {
+				p = p + "/128"
+			}
+		}
+		_, network, err := net.ParseCIDR(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy: %v", err)
+		}
+		i.proxies = append(i.proxies, network)
+	}
+	return i, nil
+}
+
+func (i *Identity) trusted(addr string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, p := range i.proxies {
+		if p.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// forwarded gets the client address reported by a trusted proxy (the connection address otherwise)
+func (i *Identity) forwarded(req *http.Request) string {
+	remote := GetClient(req)
+	if !i.trusted(remote) {
+		return remote
+	}
+	// NOTE: the right-most untrusted address is the client, addresses before it can be set by anyone
+	if fwd := req.Header.Get("X-Forwarded-For"); fwd != "" {
+		hops := strings.Split(fwd, ",")
+		for idx := len(hops) - 1; idx >= 0; idx-- {
+			hop := strings.TrimSpace(hops[idx])
+			if net.ParseIP(hop) == nil {
+				break
+			}
+			if !i.trusted(hop) {
+				return hop
+			}
+			remote = hop
+		}
+		return remote
+	}
+	if addr := strings.TrimSpace(req.Header.Get("X-Real-IP")); net.ParseIP(addr) != nil {
+		return addr
+	}
+	return remote
+}
+
+// device gets the device of a request ("" if there is no valid device cookie)
+func (i *Identity) device(req *http.Request) string {
+	c, err := req.Cookie(deviceCookie)
+	if err != nil {
+		return ""
+	}
+	value, ok := i.signer.Verify(c.Value)
+	if !ok || !strings.HasPrefix(value, devicePrefix) {
+		return ""
+	}
+	return strings.TrimPrefix(value, devicePrefix)
+}
+
+// Bind issues a device cookie (when identifying by cookie) if the request has none
+func (i *Identity) Bind(resp http.ResponseWriter, req *http.Request) {
+	if i.strategy != IdentityCookie || i.device(req) != "" {
+		return
+	}
+	http.SetCookie(resp, &http.Cookie{
+		Name:     deviceCookie,
+		Value:    i.signer.Sign(devicePrefix + NewSession(deviceLength)),
+		Path:     "/",
+		MaxAge:   int(deviceLifetime.Seconds()),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// Client gets the client a request is from (a device without a cookie is identified by its address)
+func (i *Identity) Client(req *http.Request) string {
+	switch i.strategy {
+	case IdentityCookie:
+		if d := i.device(req); d != "" {
+			return d
+		}
+	case IdentityProxy:
+		return i.forwarded(req)
+	}
+	return GetClient(req)
+}
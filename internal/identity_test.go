@@ -0,0 +1,103 @@
+package internal
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNewIdentity(t *testing.T) {
+	tests := []struct {
+		strategy string
+		proxies  []string
+		valid    bool
+	}{
+		{"", nil, true},
+		{IdentityRemote, nil, true},
+		{IdentityCookie, nil, true},
+		{IdentityProxy, []string{"10.0.0.1", "192.168.0.0/16", "::1"}, true},
+		{IdentityProxy, nil, false},
+		{IdentityProxy, []string{"not-an-address"}, false},
+		{"header", nil, false},
+	}
+	for _, test := range tests {
+		_, err := NewIdentity(test.strategy, test.proxies, &Signer{key: []byte("key")})
+		if (err == nil) != test.valid {
+			t.Errorf("%s %v: got error %v, want valid %v", test.strategy, test.proxies, err, test.valid)
+		}
+	}
+}
+
+func TestIdentityForwarded(t *testing.T) {
+	i, err := NewIdentity(IdentityProxy, []string{"10.0.0.1", "192.168.0.0/16"}, &Signer{key: []byte("key")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		remote  string
+		headers map[string]string
+		client  string
+	}{
+		{"direct", "203.0.113.5:1234", nil, "203.0.113.5"},
+		{"untrusted forwarding", "203.0.113.5:1234", map[string]string{"X-Forwarded-For": "198.51.100.1"}, "203.0.113.5"},
+		{"trusted proxy", "10.0.0.1:1234", map[string]string{"X-Forwarded-For": "198.51.100.1"}, "198.51.100.1"},
+		{"spoofed hops", "10.0.0.1:1234", map[string]string{"X-Forwarded-For": "1.2.3.4, 198.51.100.1"}, "198.51.100.1"},
+		{"proxy chain", "10.0.0.1:1234", map[string]string{"X-Forwarded-For": "198.51.100.1, 192.168.1.1"}, "198.51.100.1"},
+		{"only proxies", "10.0.0.1:1234", map[string]string{"X-Forwarded-For": "192.168.1.1"}, "192.168.1.1"},
+		{"invalid hop", "10.0.0.1:1234", map[string]string{"X-Forwarded-For": "garbage"}, "10.0.0.1"},
+		{"real ip", "10.0.0.1:1234", map[string]string{"X-Real-IP": "198.51.100.2"}, "198.51.100.2"},
+		{"invalid real ip", "10.0.0.1:1234", map[string]string{"X-Real-IP": "garbage"}, "10.0.0.1"},
+		{"forwarded over real ip", "10.0.0.1:1234", map[string]string{"X-Forwarded-For": "198.51.100.1", "X-Real-IP": "198.51.100.2"}, "198.51.100.1"},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = test.remote
+		for k, v := range test.headers {
+			req.Header.Set(k, v)
+		}
+		if got := i.Client(req); got != test.client {
+			t.Errorf("%s: got %s, want %s", test.name, got, test.client)
+		}
+	}
+}
+
+func TestIdentityCookie(t *testing.T) {
+	signer := &Signer{key: []byte("key")}
+	i, err := NewIdentity(IdentityCookie, nil, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "203.0.113.5:1234"
+	if got := i.Client(req); got != "203.0.113.5" {
+		t.Errorf("client without a device: %s", got)
+	}
+	resp := httptest.NewRecorder()
+	i.Bind(resp, req)
+	cookies := resp.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != deviceCookie {
+		t.Fatalf("unexpected cookies: %v", cookies)
+	}
+	req.AddCookie(cookies[0])
+	device := i.Client(req)
+	if len(device) != deviceLength || device == "203.0.113.5" {
+		t.Errorf("unexpected device: %s", device)
+	}
+	again := httptest.NewRecorder()
+	i.Bind(again, req)
+	if len(again.Result().Cookies()) != 0 {
+		t.Error("device cookie issued twice")
+	}
+	forged := httptest.NewRequest(http.MethodGet, "/", nil)
+	forged.RemoteAddr = "203.0.113.5:1234"
+	forged.AddCookie(&http.Cookie{Name: deviceCookie, Value: devicePrefix + "forged.signature"})
+	if got := i.Client(forged); got != "203.0.113.5" {
+		t.Errorf("forged device accepted: %s", got)
+	}
+	unsigned := httptest.NewRequest(http.MethodGet, "/", nil)
+	unsigned.AddCookie(&http.Cookie{Name: deviceCookie, Value: signer.Sign("session")})
+	if got := i.device(unsigned); got != "" {
+		t.Errorf("signed value without the device prefix accepted: %s", got)
+	}
+}
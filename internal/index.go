@@ -0,0 +1,441 @@
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// IndexLogBackend stores the index as an append-only (fsync'd) log
+	IndexLogBackend = "log"
+	// IndexManifestBackend stores the index as a (rewritten) JSON manifest file
+	IndexManifestBackend = "manifest"
+	// SaveMode is the mode of a final survey submission
+	SaveMode     = "save"
+	manifestExt  = ".index.manifest"
+	indexLogExt  = ".index.log"
+	tempFileExt  = ".tmp"
+	resultExt    = ".json"
+	resultPrefix = "_"
+)
+
+type (
+	// IndexStore records the result files (and the current one) of each session
+	IndexStore interface {
+		// Record stores a new result file for a client's session (in order of calls)
+		Record(client, session string, result ManifestResult) error
+		// Manifest gets a copy of the current manifest (without history)
+		Manifest() *Manifest
+		// History gets a copy of the current manifest with the history of each session
+		History() *Manifest
+		// Latest gets the most recently recorded result file of a session ("" if none exists)
+		Latest(session string) string
+		// Path is the on-disk location of the index
+		Path() string
+		// Close releases the index
+		Close() error
+	}
+
+	indexRecord struct {
+		Seq       int    `json:"seq"`
+		Client    string `json:"client"`
+		Session   string `json:"session,omitempty"`
+		File      string `json:"file"`
+		Mode      string `json:"mode"`
+		Timestamp string `json:"timestamp,omitempty"`
+	}
+
+	logIndex struct {
+		sync.Mutex
+		path     string
+		file     *os.File
+		seq      int
+		offset   int64
+		manifest *Manifest
+	}
+
+	manifestIndex struct {
+		sync.Mutex
+		path     string
+		manifest *Manifest
+	}
+)
+
+// sessionOf gets the session of a result name (the last part, see fileStore.put)
+func sessionOf(name string) string {
+	parts := strings.Split(name, resultPrefix)
+	return parts[len(parts)-1]
+}
+
+// timestampOf gets the timestamp of a result name ("" if it has none, see fileStore.put)
+func timestampOf(name string) string {
+	for _, part := range strings.Split(name, resultPrefix) {
+		if _, err := time.Parse(timeFormat, part); err == nil {
+			return part
+		}
+	}
+	return ""
+}
+
+// Update applies a new result file for a session, it is added to the session's history and
+// becomes the latest result (a 'save' is only replaced by another 'save')
+func (manifest *Manifest) Update(client, session string, result ManifestResult) {
+	if session == "" {
+		session = sessionOf(result.File)
+	}
+	if result.Timestamp == "" {
+		result.Timestamp = timestampOf(result.File)
+	}
+	for i, s := range manifest.Sessions {
+		if s != session {
+			continue
+		}
+		manifest.History[i] = append(manifest.History[i], result)
+		if manifest.Modes[i] != SaveMode || result.Mode == SaveMode {
+			manifest.Clients[i] = client
+			manifest.Files[i] = result.File
+			manifest.Modes[i] = result.Mode
+		}
+		return
+	}
+	manifest.Sessions = append(manifest.Sessions, session)
+	manifest.Clients = append(manifest.Clients, client)
+	manifest.Files = append(manifest.Files, result.File)
+	manifest.Modes = append(manifest.Modes, result.Mode)
+	manifest.History = append(manifest.History, []ManifestResult{result})
+}
+
+// Latest gets the most recently recorded result file of a session ("" if none exists)
+func (manifest *Manifest) Latest(session string) string {
+	for i, s := range manifest.Sessions {
+		if s != session {
+			continue
+		}
+		if i < len(manifest.History) && len(manifest.History[i]) > 0 {
+			return manifest.History[i][len(manifest.History[i])-1].File
+		}
+		return manifest.Files[i]
+	}
+	return ""
+}
+
+func (manifest *Manifest) copy(history bool) *Manifest {
+	m := &Manifest{
+		Files:    append([]string{}, manifest.Files...),
+		Clients:  append([]string{}, manifest.Clients...),
+		Modes:    append([]string{}, manifest.Modes...),
+		Sessions: append([]string{}, manifest.Sessions...),
+	}
+	if !history {
+		return m
+	}
+	for _, h := range manifest.History {
+		m.History = append(m.History, append([]ManifestResult{}, h...))
+	}
+	return m
+}
+
+// OpenIndex opens (and recovers) the index for a tag using the given backend
+func OpenIndex(dir, tag, backend string) (IndexStore, error) {
+	switch SetIfEmpty(backend, IndexLogBackend) {
+	case IndexLogBackend:
+		return openLogIndex(dir, tag)
+	case IndexManifestBackend:
+		return openManifestIndex(dir, tag)
+	}
+	return nil, fmt.Errorf("unknown index backend: %s", backend)
+}
+
+// LoadManifest reads a manifest from either a manifest file or an index log
+func LoadManifest(path string) (*Manifest, error) {
+	if strings.HasSuffix(path, indexLogExt) {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		m, _, _, err := replayLog(f)
+		return m, err
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	m, err := NewManifest(b)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.Check(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// replayLog reads log records until the end, a final record without a newline is incomplete (and not replayed),
+// an unreadable record anywhere else is corruption
+func replayLog(r io.Reader) (*Manifest, int, int64, error) {
+	m := &Manifest{}
+	seq := 0
+	var valid int64
+	reader := bufio.NewReader(r)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, 0, 0, err
+		}
+		rec := &indexRecord{}
+		if err := json.Unmarshal(bytes.TrimSpace(line), rec); err != nil {
+			return nil, 0, 0, fmt.Errorf("index log corrupt after record %d: %v", seq, err)
+		}
+		if rec.Seq <= seq {
+			return nil, 0, 0, fmt.Errorf("index log out of order at record %d", rec.Seq)
+		}
+		seq = rec.Seq
+		m.Update(rec.Client, rec.Session, ManifestResult{File: rec.File, Mode: rec.Mode, Timestamp: rec.Timestamp})
+		valid += int64(len(line))
+	}
+	return m, seq, valid, nil
+}
+
+func openLogIndex(dir, tag string) (*logIndex, error) {
+	idx := &logIndex{path: filepath.Join(dir, fmt.Sprintf("%s%s", tag, indexLogExt))}
+	existed := PathExists(idx.path)
+	f, err := os.OpenFile(idx.path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+	m, seq, valid, err := replayLog(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	stat, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if stat.Size() != valid {
+		Info(fmt.Sprintf("recovering index log %s, dropping %d bytes of incomplete records", idx.path, stat.Size()-valid))
+		if err := f.Truncate(valid); err != nil {
+			f.Close()
+			return nil, err
+		}
+		if err := f.Sync(); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	if _, err := f.Seek(valid, io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
+	}
+	idx.file = f
+	idx.seq = seq
+	idx.offset = valid
+	idx.manifest = m
+	if !existed {
+		// NOTE: carry forward an existing (legacy) manifest into the new log
+		legacy, err := openManifestIndex(dir, tag)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		old := legacy.History()
+		for i := range old.Files {
+			for _, result := range old.History[i] {
+				if err := idx.Record(old.Clients[i], old.Sessions[i], result); err != nil {
+					f.Close()
+					return nil, err
+				}
+			}
+		}
+	}
+	return idx, nil
+}
+
+func (idx *logIndex) Record(client, session string, result ManifestResult) error {
+	idx.Lock()
+	defer idx.Unlock()
+	rec := &indexRecord{Seq: idx.seq + 1, Client: client, Session: session, File: result.File, Mode: result.Mode, Timestamp: result.Timestamp}
+	datum, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	line := append(datum, '\n')
+	if _, err := idx.file.Write(line); err != nil {
+		return idx.rollback(err)
+	}
+	if err := idx.file.Sync(); err != nil {
+		return idx.rollback(err)
+	}
+	idx.offset += int64(len(line))
+	idx.seq = rec.Seq
+	idx.manifest.Update(client, session, result)
+	return nil
+}
+
+// rollback drops a (partially) written record so later records are not appended after it
+func (idx *logIndex) rollback(err error) error {
+	if terr := idx.file.Truncate(idx.offset); terr != nil {
+		return fmt.Errorf("%v (and unable to drop the partial record: %v)", err, terr)
+	}
+	if _, serr := idx.file.Seek(idx.offset, io.SeekStart); serr != nil {
+		return fmt.Errorf("%v (and unable to drop the partial record: %v)", err, serr)
+	}
+	return err
+}
+
+func (idx *logIndex) Manifest() *Manifest {
+	idx.Lock()
+	defer idx.Unlock()
+	return idx.manifest.copy(false)
+}
+
+func (idx *logIndex) History() *Manifest {
+	idx.Lock()
+	defer idx.Unlock()
+	return idx.manifest.copy(true)
+}
+
+func (idx *logIndex) Latest(session string) string {
+	idx.Lock()
+	defer idx.Unlock()
+	return idx.manifest.Latest(session)
+}
+
+func (idx *logIndex) Path() string {
+	return idx.path
+}
+
+func (idx *logIndex) Close() error {
+	idx.Lock()
+	defer idx.Unlock()
+	return idx.file.Close()
+}
+
+func openManifestIndex(dir, tag string) (*manifestIndex, error) {
+	fname, existing, err := ReadManifestFile(dir, tag)
+	if err != nil {
+		Info(fmt.Sprintf("rebuilding index %s from result files", fname))
+		existing, err = RebuildManifest(dir, tag)
+		if err != nil {
+			return nil, err
+		}
+		if err := writeAtomic(fname, existing); err != nil {
+			return nil, err
+		}
+	}
+	return &manifestIndex{path: fname, manifest: existing}, nil
+}
+
+func (idx *manifestIndex) Record(client, session string, result ManifestResult) error {
+	idx.Lock()
+	defer idx.Unlock()
+	idx.manifest.Update(client, session, result)
+	return writeAtomic(idx.path, idx.manifest)
+}
+
+func (idx *manifestIndex) Manifest() *Manifest {
+	idx.Lock()
+	defer idx.Unlock()
+	return idx.manifest.copy(false)
+}
+
+func (idx *manifestIndex) History() *Manifest {
+	idx.Lock()
+	defer idx.Unlock()
+	return idx.manifest.copy(true)
+}
+
+func (idx *manifestIndex) Latest(session string) string {
+	idx.Lock()
+	defer idx.Unlock()
+	return idx.manifest.Latest(session)
+}
+
+func (idx *manifestIndex) Path() string {
+	return idx.path
+}
+
+func (idx *manifestIndex) Close() error {
+	return nil
+}
+
+// writeAtomic writes the manifest to a temporary file and then moves it into place
+func writeAtomic(filename string, manifest *Manifest) error {
+	datum, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+	tmp := filename + tempFileExt
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(datum); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filename)
+}
+
+// RebuildManifest recreates a manifest by replaying the result files of a tag (oldest first)
+func RebuildManifest(dir, tag string) (*Manifest, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	prefix := tag + resultPrefix
+	var results []os.FileInfo
+	for _, f := range files {
+		if strings.HasPrefix(f.Name(), prefix) && strings.HasSuffix(f.Name(), resultExt) {
+			results = append(results, f)
+		}
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].ModTime().Before(results[j].ModTime())
+	})
+	m := &Manifest{}
+	for _, f := range results {
+		name := strings.TrimSuffix(f.Name(), resultExt)
+		parts := strings.Split(strings.TrimPrefix(name, prefix), resultPrefix)
+		if len(parts) < 2 {
+			continue
+		}
+		b, err := ioutil.ReadFile(filepath.Join(dir, f.Name()))
+		if err != nil {
+			return nil, err
+		}
+		r := &ResultData{}
+		if err := json.Unmarshal(b, r); err != nil {
+			Info(fmt.Sprintf("skipping unreadable result file %s", f.Name()))
+			continue
+		}
+		client := r.Datum[ClientKey]
+		if len(client) == 0 {
+			continue
+		}
+		m.Update(client[0], sessionOf(name), ManifestResult{File: name, Mode: parts[1], Timestamp: parts[0]})
+	}
+	return m, nil
+}
@@ -0,0 +1,138 @@
+package internal
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReplayLog(t *testing.T) {
+	first := `{"seq":1,"client":"c1","session":"s1","file":"t_2020-01-01T00-00-00_snapshot_c1_x_s1","mode":"snapshot"}`
+	second := `{"seq":2,"client":"c1","session":"s1","file":"t_2020-01-01T00-00-01_save_c1_x_s1","mode":"save"}`
+	third := `{"seq":3,"client":"c2","session":"s2","file":"t_2020-01-01T00-00-02_snapshot_c2_x_s2","mode":"snapshot"}`
+	tests := []struct {
+		name     string
+		log      string
+		seq      int
+		valid    int
+		sessions int
+		err      string
+	}{
+		{"empty", "", 0, 0, 0, ""},
+		{"complete", first + "\n" + second + "\n" + third + "\n", 3, len(first+second+third) + 3, 2, ""},
+		{"partial final line", first + "\n" + second + "\n" + third[0:20], 2, len(first+second) + 2, 1, ""},
+		{"final line without newline", first + "\n" + third, 1, len(first) + 1, 1, ""},
+		{"corrupt record", first + "\n" + second[0:20] + "\n" + third + "\n", 0, 0, 0, "index log corrupt after record 1"},
+		{"out of order", second + "\n" + first + "\n", 0, 0, 0, "index log out of order at record 1"},
+	}
+	for _, test := range tests {
+		m, seq, valid, err := replayLog(strings.NewReader(test.log))
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%s: got error %v, want %s", test.name, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", test.name, err)
+			continue
+		}
+		if seq != test.seq || valid != int64(test.valid) || len(m.Sessions) != test.sessions {
+			t.Errorf("%s: got seq %d, valid %d, sessions %d, want %d, %d, %d", test.name, seq, valid, len(m.Sessions), test.seq, test.valid, test.sessions)
+		}
+	}
+}
+
+func TestOpenLogIndexRecovery(t *testing.T) {
+	dir, err := ioutil.TempDir("", "index")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	idx, err := openLogIndex(dir, "t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range []ManifestResult{{File: "t_2020-01-01T00-00-00_snapshot_c_x_s", Mode: SnapshotMode}, {File: "t_2020-01-01T00-00-01_save_c_x_s", Mode: SaveMode}} {
+		if err := idx.Record("c", "s", r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	idx.Close()
+	path := filepath.Join(dir, "t"+indexLogExt)
+	complete, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// NOTE: a crash while appending leaves a partial final record
+	if err := ioutil.WriteFile(path, append(complete, []byte(`{"seq":3,"cli`)...), 0644); err != nil {
+		t.Fatal(err)
+	}
+	idx, err = openLogIndex(dir, "t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m := idx.History(); len(m.Sessions) != 1 || m.Modes[0] != SaveMode || len(m.History[0]) != 2 {
+		t.Errorf("unexpected recovered manifest: %v", m)
+	}
+	if latest := idx.Latest("s"); latest != "t_2020-01-01T00-00-01_save_c_x_s" {
+		t.Errorf("unexpected latest result: %s", latest)
+	}
+	if err := idx.Record("c", "s", ManifestResult{File: "t_2020-01-01T00-00-02_snapshot_c_x_s", Mode: SnapshotMode}); err != nil {
+		t.Fatal(err)
+	}
+	idx.Close()
+	recovered, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(recovered), string(complete)) || strings.Count(string(recovered), "\n") != 3 {
+		t.Errorf("partial record was not truncated: %s", recovered)
+	}
+	// NOTE: corruption before the final record is not repaired
+	lines := strings.Split(string(recovered), "\n")
+	lines[1] = lines[1][0:10]
+	if err := ioutil.WriteFile(path, []byte(strings.Join(lines, "\n")), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := openLogIndex(dir, "t"); err == nil {
+		t.Error("corrupt index log was opened")
+	}
+}
+
+func TestLogIndexRollback(t *testing.T) {
+	dir, err := ioutil.TempDir("", "index")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	idx, err := openLogIndex(dir, "t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := idx.Record("c", "s1", ManifestResult{File: "t_2020-01-01T00-00-00_snapshot_c_x_s1", Mode: SnapshotMode}); err != nil {
+		t.Fatal(err)
+	}
+	// NOTE: a failed write (e.g. out of space) can leave part of a record behind
+	if _, err := idx.file.Write([]byte(`{"seq":2,"cli`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := idx.rollback(errors.New("no space left on device")); err == nil || err.Error() != "no space left on device" {
+		t.Fatalf("unexpected rollback error: %v", err)
+	}
+	if err := idx.Record("c", "s2", ManifestResult{File: "t_2020-01-01T00-00-01_snapshot_c_x_s2", Mode: SnapshotMode}); err != nil {
+		t.Fatal(err)
+	}
+	idx.Close()
+	reopened, err := openLogIndex(dir, "t")
+	if err != nil {
+		t.Fatalf("index log did not reopen after a failed write: %v", err)
+	}
+	defer reopened.Close()
+	if m := reopened.Manifest(); len(m.Sessions) != 2 {
+		t.Errorf("unexpected sessions: %v", m.Sessions)
+	}
+}
@@ -0,0 +1,216 @@
+package internal
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	// ParticipantKey contains the invitation code a result was submitted with
+	ParticipantKey = "participant"
+	// InviteLength is the length of generated invitation codes
+	InviteLength    = 12
+	inviteMaxLength = 64
+	completedExt    = ".completed"
+)
+
+type (
+	// Invites are the participant codes allowed to take a survey (a code is the participant's session)
+	Invites struct {
+		sync.Mutex
+		path      string
+		codes     map[string]bool
+		completed map[string]bool
+	}
+
+	// InviteEntry is the status of an invitation code
+	InviteEntry struct {
+		Code   string
+		Status string
+	}
+)
+
+// ValidInvite checks an invitation code is usable as a session (lowercase alphanumerics or '_')
+func ValidInvite(code string) bool {
+	return code != "" && len(code) <= inviteMaxLength && CleanName(code) == code
+}
+
+// NormalizeInvite gets the form an invitation code is issued (and kept) in
+func NormalizeInvite(code string) string {
+	return strings.ToLower(strings.TrimSpace(code))
+}
+
+// readCodes reads the (valid) codes kept at a path, one per line
+func readCodes(path string, codes map[string]bool) error {
+	if !PathExists(path) {
+		return nil
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	for idx, line := range strings.Split(string(b), "\n") {
+		code := NormalizeInvite(line)
+		if code == "" {
+			continue
+		}
+		if !ValidInvite(code) {
+			Info(fmt.Sprintf("skipping invalid invitation code on line %d of %s", idx+1, path))
+			continue
+		}
+		codes[code] = true
+	}
+	return nil
+}
+
+// appendCodes appends codes to the file at a path
+func appendCodes(path string, codes []string) error {
+	// NOTE: codes are appended so the file is never rewritten (or truncated) on failure
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := f.WriteString(strings.Join(codes, "\n") + "\n"); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+// LoadInvites reads the invitation codes (one per line) kept at a path, invalid codes are skipped,
+// completed codes are kept next to them (so they stay used across tags and restarts)
+func LoadInvites(path string) (*Invites, error) {
+	i := &Invites{path: path, codes: make(map[string]bool), completed: make(map[string]bool)}
+	if err := readCodes(path, i.codes); err != nil {
+		return nil, err
+	}
+	if err := readCodes(path+completedExt, i.completed); err != nil {
+		return nil, err
+	}
+	return i, nil
+}
+
+// Known checks if a code has been issued
+func (i *Invites) Known(code string) bool {
+	i.Lock()
+	defer i.Unlock()
+	return i.codes[NormalizeInvite(code)]
+}
+
+// Completed checks if a code has been used for a final (saved) result
+func (i *Invites) Completed(code string) bool {
+	i.Lock()
+	defer i.Unlock()
+	return i.completed[NormalizeInvite(code)]
+}
+
+// Complete records a code as used
+func (i *Invites) Complete(code string) error {
+	i.Lock()
+	defer i.Unlock()
+	code = NormalizeInvite(code)
+	if i.completed[code] {
+		return nil
+	}
+	if err := appendCodes(i.path+completedExt, []string{code}); err != nil {
+		return err
+	}
+	i.completed[code] = true
+	return nil
+}
+
+// Codes gets the issued codes (sorted)
+func (i *Invites) Codes() []string {
+	i.Lock()
+	defer i.Unlock()
+	var codes []string
+	for c := range i.codes {
+		codes = append(codes, c)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// Add issues codes (normalized to lowercase), returning the number added
+func (i *Invites) Add(codes []string) (int, error) {
+	var add []string
+	for _, c := range codes {
+		code := NormalizeInvite(c)
+		if code == "" {
+			continue
+		}
+		if !ValidInvite(code) {
+			return 0, fmt.Errorf("invalid invitation code: %s", c)
+		}
+		add = append(add, code)
+	}
+	i.Lock()
+	defer i.Unlock()
+	return i.issue(add)
+}
+
+// Generate issues new random codes
+func (i *Invites) Generate(count int) ([]string, error) {
+	i.Lock()
+	defer i.Unlock()
+	var codes []string
+	for len(codes) < count {
+		code := NewSession(InviteLength)
+		if i.codes[code] {
+			continue
+		}
+		codes = append(codes, code)
+	}
+	if _, err := i.issue(codes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+func (i *Invites) issue(codes []string) (int, error) {
+	var added []string
+	for _, c := range codes {
+		if !i.codes[c] {
+			added = append(added, c)
+		}
+	}
+	if len(added) == 0 {
+		return 0, nil
+	}
+	if err := appendCodes(i.path, added); err != nil {
+		return 0, err
+	}
+	for _, c := range added {
+		i.codes[c] = true
+	}
+	return len(added), nil
+}
+
+// Saved checks if a session has a final (saved) result in a manifest
+func (manifest *Manifest) Saved(session string) bool {
+	for idx, s := range manifest.Sessions {
+		if s == session && manifest.Modes[idx] == SaveMode {
+			return true
+		}
+	}
+	return false
+}
+
+// Status gets an invitation's progress (completed, started or unused) from a manifest
+func (manifest *Manifest) Status(session string) string {
+	status := "unused"
+	for idx, s := range manifest.Sessions {
+		if s != session {
+			continue
+		}
+		if manifest.Modes[idx] == SaveMode {
+			return "completed"
+		}
+		status = "started"
+	}
+	return status
+}
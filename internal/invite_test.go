@@ -0,0 +1,51 @@
+package internal
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestInvites(t *testing.T) {
+	dir, err := ioutil.TempDir("", "invites")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "invites")
+	if err := ioutil.WriteFile(path, []byte("Code1\n code2 \n\nbad-code\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	i, err := LoadInvites(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := i.Complete("CODE2"); err != nil {
+		t.Fatal(err)
+	}
+	reloaded, err := LoadInvites(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		code      string
+		known     bool
+		completed bool
+	}{
+		{"code1", true, false},
+		{"CODE1", true, false},
+		{"code2", true, true},
+		{" Code2", true, true},
+		{"bad-code", false, false},
+		{"other", false, false},
+	}
+	for _, test := range tests {
+		if reloaded.Known(test.code) != test.known || reloaded.Completed(test.code) != test.completed {
+			t.Errorf("%s: got known %v, completed %v", test.code, reloaded.Known(test.code), reloaded.Completed(test.code))
+		}
+	}
+	if _, err := i.Add([]string{"in valid"}); err == nil {
+		t.Error("invalid code added")
+	}
+}
@@ -0,0 +1,101 @@
+package internal
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	// JoinedCSV is the output suffix of joined runs
+	JoinedCSV = ".joined.csv"
+)
+
+type (
+	joinedRun struct {
+		name      string
+		questions []string
+		// answers are by client, a client with several sessions in a run has several (oldest first)
+		answers map[string][]map[string]string
+	}
+)
+
+// Join stitches each input (a run) and joins them on the client (e.g. an hmac pseudonym), writing a row
+// per client with the answers (and session) of every run (named by the input's output name) as columns,
+// a client with several sessions in a run gets a row per session (the n-th sessions of each run are joined)
+func Join(inputs []Inputs, w io.Writer) error {
+	var runs []*joinedRun
+	clients := make(map[string]bool)
+	for _, in := range inputs {
+		result, _, _, err := in.result()
+		if err != nil {
+			Info(fmt.Sprintf("unable to join %s with %s (%v)", in.Manifest, in.Config, err))
+			continue
+		}
+		run := &joinedRun{name: filepath.Base(in.OutName), answers: make(map[string][]map[string]string)}
+		seen := make(map[string]bool)
+		for _, o := range result.Objects {
+			answers := map[string]string{SessionKey: strings.Join(o.results.Datum[SessionKey], " ")}
+			for _, resp := range o.Responses {
+				if resp.Question == ClientKey {
+					continue
+				}
+				if !seen[resp.Question] {
+					seen[resp.Question] = true
+					run.questions = append(run.questions, resp.Question)
+				}
+				answers[resp.Question] = resp.Answer
+			}
+			run.answers[o.client] = append(run.answers[o.client], answers)
+			clients[o.client] = true
+		}
+		for client, sessions := range run.answers {
+			if len(sessions) > 1 {
+				Info(fmt.Sprintf("client %s has %d sessions in %s, joining a row per session", client, len(sessions), run.name))
+			}
+		}
+		runs = append(runs, run)
+	}
+	if len(runs) == 0 {
+		return fmt.Errorf("no runs to join")
+	}
+	header := []string{ClientKey}
+	for _, run := range runs {
+		header = append(header, fmt.Sprintf("%s: %s", run.name, SessionKey))
+		for _, q := range run.questions {
+			header = append(header, fmt.Sprintf("%s: %s", run.name, q))
+		}
+	}
+	var names []string
+	for c := range clients {
+		names = append(names, c)
+	}
+	sort.Strings(names)
+	records := [][]string{header}
+	for _, client := range names {
+		count := 0
+		for _, run := range runs {
+			if len(run.answers[client]) > count {
+				count = len(run.answers[client])
+			}
+		}
+		for idx := 0; idx < count; idx++ {
+			row := []string{client}
+			for _, run := range runs {
+				answers := make(map[string]string)
+				if idx < len(run.answers[client]) {
+					answers = run.answers[client][idx]
+				}
+				row = append(row, answers[SessionKey])
+				for _, q := range run.questions {
+					row = append(row, answers[q])
+				}
+			}
+			records = append(records, row)
+		}
+	}
+	return csv.NewWriter(w).WriteAll(records)
+}
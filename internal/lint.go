@@ -0,0 +1,292 @@
+package internal
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+var (
+	// NOTE: yaml errors are reported as 'line N: ...'
+	yamlErrorLine = regexp.MustCompile(`line (\d+): (.*)`)
+	questionTypes = map[string]bool{
+		"input":       true,
+		"hidden":      true,
+		"long":        true,
+		"option":      true,
+		"multiselect": true,
+		"order":       true,
+		"label":       true,
+		"checkbox":    true,
+		"number":      true,
+		"image":       true,
+		"audio":       true,
+		"video":       true,
+		"hr":          true,
+		"pagebreak":   true,
+		"slide":       true,
+		"uslide":      true,
+		"conditional": true,
+	}
+	optionTypes = map[string]bool{
+		"option":      true,
+		"multiselect": true,
+		"order":       true,
+	}
+	mediaTypes = map[string]bool{
+		"image": true,
+		"audio": true,
+		"video": true,
+	}
+)
+
+type (
+	// LintProblem is a problem found in a question definition
+	LintProblem struct {
+		Line    int
+		Message string
+	}
+
+	lintBlock struct {
+		line  int
+		count int
+	}
+)
+
+// questionLines finds the (1-based) line each question (list item) starts on
+func questionLines(data []byte) []int {
+	var lines []int
+	in := false
+	indent := -1
+	for idx, line := range strings.Split(string(data), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		level := len(line) - len(strings.TrimLeft(line, " "))
+		if level == 0 && !strings.HasPrefix(trimmed, "-") {
+			in = strings.HasPrefix(trimmed, "questions:")
+			indent = -1
+			continue
+		}
+		if !in || !strings.HasPrefix(trimmed, "-") {
+			continue
+		}
+		if indent < 0 {
+			indent = level
+		}
+		if level == indent {
+			lines = append(lines, idx+1)
+		}
+	}
+	return lines
+}
+
+// LoadConfig reads a question definition, it is rejected with any problem Lint reports (media files are not checked)
+func LoadConfig(data []byte) (*Config, error) {
+	if problems := Lint(data, ""); len(problems) > 0 {
+		var messages []string
+		for _, p := range problems {
+			if p.Line > 0 {
+				messages = append(messages, fmt.Sprintf("line %d: %s", p.Line, p.Message))
+			} else {
+				messages = append(messages, p.Message)
+			}
+		}
+		return nil, fmt.Errorf("invalid question definition: %s", strings.Join(messages, "; "))
+	}
+	var config Config
+	if err := yaml.UnmarshalStrict(data, &config); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+// Lint checks a question definition, resources is the static resource directory (media is not checked when empty)
+func Lint(data []byte, resources string) []LintProblem {
+	var problems []LintProblem
+	add := func(line int, format string, args ...interface{}) {
+		problems = append(problems, LintProblem{Line: line, Message: fmt.Sprintf(format, args...)})
+	}
+	var config Config
+	if err := yaml.UnmarshalStrict(data, &config); err != nil {
+		found := false
+		for _, m := range yamlErrorLine.FindAllStringSubmatch(err.Error(), -1) {
+			line, _ := strconv.Atoi(m[1])
+			add(line, "%s", strings.TrimSpace(m[2]))
+			found = true
+		}
+		if !found {
+			add(0, "%s", err.Error())
+		}
+		// NOTE: unknown fields are still decoded (leniently) to check the rest
+		if err := yaml.Unmarshal(data, &config); err != nil {
+			return problems
+		}
+	}
+	lines := questionLines(data)
+	lineOf := func(idx int) int {
+		if idx >= 0 && idx < len(lines) {
+			return lines[idx]
+		}
+		return 0
+	}
+	if len(config.Questions) == 0 {
+		add(0, "no questions defined")
+	}
+	ids := make(map[int]int)
+	keys := make(map[string]int)
+	known := make(map[string]bool)
+	var conds []*lintBlock
+	explicit := ExplicitEnds(config.Questions)
+	for idx, q := range config.Questions {
+		line := lineOf(idx)
+		for _, c := range conds {
+			c.count++
+		}
+		id, key := q.Keys(idx)
+		if prev, ok := ids[id]; ok {
+			add(line, "duplicate question number %d (also used on line %d)", id, lineOf(prev))
+		} else {
+			ids[id] = idx
+		}
+		if !ValidKey(key) {
+			add(line, "invalid question id: %s", key)
+		}
+		if prev, ok := keys[key]; ok {
+			add(line, "duplicate question id %s (also used on line %d)", key, lineOf(prev))
+		} else {
+			keys[key] = idx
+		}
+		if !questionTypes[q.Type] {
+			add(line, "unknown question type: %s", q.Type)
+		}
+		for _, attr := range q.Attributes {
+			if attr != "required" {
+				add(line, "unknown attribute: %s", attr)
+			}
+		}
+		if len(q.Options) > 0 && !optionTypes[q.Type] {
+			add(line, "options are not used by %s questions", q.Type)
+		}
+		if q.End && q.Type != "conditional" {
+			add(line, "end is not used by %s questions", q.Type)
+		}
+		if q.Randomize && !optionTypes[q.Type] {
+			add(line, "randomize is not used by %s questions", q.Type)
+		}
+		if optionTypes[q.Type] && len(q.Options) == 0 {
+			add(line, "%s question has no options", q.Type)
+		}
+		switch q.Type {
+		case "slide", "uslide":
+			if q.Basis != "" {
+				v, err := strconv.ParseFloat(q.Basis, 64)
+				if err != nil {
+					add(line, "slider basis is not a number: %s", q.Basis)
+				} else if v < SlideMin || v > SlideMax {
+					add(line, "slider basis %s is outside of %.0f-%.0f", q.Basis, SlideMin, SlideMax)
+				}
+			}
+		case "pagebreak":
+			if len(conds) > 0 {
+				add(line, "pagebreak inside of a conditional (opened on line %d)", conds[len(conds)-1].line)
+			}
+		case "conditional":
+			if q.ClosesConditional(explicit, len(conds)) {
+				if len(conds) == 0 {
+					add(line, "conditional end without an open conditional")
+				} else {
+					last := conds[len(conds)-1]
+					if last.count == 1 {
+						add(line, "conditional (opened on line %d) contains no questions", last.line)
+					}
+					conds = conds[0 : len(conds)-1]
+				}
+			} else {
+				conds = append(conds, &lintBlock{line: line})
+			}
+		}
+		if mediaTypes[q.Type] {
+			if q.Basis == "" {
+				add(line, "%s question has no basis (file)", q.Type)
+			} else if resources != "" && !PathExists(filepath.Join(resources, q.Basis)) {
+				add(line, "%s file not found: %s", q.Type, filepath.Join(resources, q.Basis))
+			}
+		}
+		for _, expr := range []struct {
+			name  string
+			value string
+		}{{"show_if", q.ShowIf}, {"skip_if", q.SkipIf}} {
+			if expr.value == "" {
+				continue
+			}
+			cond, err := ParseCondition(expr.value, false)
+			if err != nil {
+				add(line, "invalid %s: %v", expr.name, err)
+				continue
+			}
+			for _, c := range cond.Clauses {
+				if !known[c.ID] {
+					add(line, "%s references unknown (or later) question: %s", expr.name, c.ID)
+				}
+			}
+		}
+		for _, ref := range append(PipeRefs(q.Text), PipeRefs(q.Description)...) {
+			if !known[ref] {
+				add(line, "piped answer references unknown (or later) question: %s", ref)
+			}
+		}
+		if q.SkipIf != "" || q.SkipTo != "" {
+			if q.SkipIf == "" {
+				add(line, "skip_to without skip_if")
+			}
+			found := false
+			for later := idx + 1; later < len(config.Questions); later++ {
+				if _, laterKey := config.Questions[later].Keys(later); laterKey == q.SkipTo {
+					found = true
+					break
+				}
+			}
+			if !found {
+				add(line, "skip target %s is not a later question", q.SkipTo)
+			}
+		}
+		known[key] = true
+	}
+	for _, c := range conds {
+		add(c.line, "unclosed conditional")
+	}
+	if _, err := NewSchedule(config.Metadata); err != nil {
+		add(0, "invalid schedule: %v", err)
+	}
+	if config.Metadata.MaxResponses < 0 {
+		add(0, "max_responses must not be negative")
+	}
+	for _, q := range config.Metadata.Quotas {
+		quota, err := NewQuota(q)
+		if err != nil {
+			add(0, "invalid quota: %v", err)
+			continue
+		}
+		for _, c := range quota.Condition().Clauses {
+			if !known[c.ID] {
+				add(0, "quota references unknown question: %s", c.ID)
+			}
+		}
+	}
+	for _, group := range config.Metadata.Randomize {
+		if idx, err := CheckRandomGroup(config.Questions, group); err != nil {
+			add(lineOf(idx), "%v", err)
+		}
+	}
+	sort.SliceStable(problems, func(i, j int) bool {
+		return problems[i].Line < problems[j].Line
+	})
+	return problems
+}
@@ -0,0 +1,36 @@
+package internal
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLoadConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		err    string
+	}{
+		{"valid", "questions:\n  - text: a\n    type: input\n    id: a\n  - text: b\n    type: option\n    options: [x, y]\n    show_if: a == x\n", ""},
+		{"duplicate id", "questions:\n  - text: a\n    type: input\n    id: a\n  - text: b\n    type: input\n    id: a\n", "line 5: "},
+		{"unknown attribute", "questions:\n  - text: a\n    type: input\n    attrs: [other]\n", "line 2: "},
+		{"later reference", "questions:\n  - text: a\n    type: input\n    show_if: b == x\n  - text: b\n    type: input\n    id: b\n", "line 2: "},
+		{"unclosed conditional", "questions:\n  - text: a\n    type: conditional\n  - text: b\n    type: input\n", "unclosed"},
+		{"unknown field", "questions:\n  - text: a\n    type: input\n    other: b\n", "invalid question definition"},
+	}
+	for _, test := range tests {
+		config, err := LoadConfig([]byte(test.config))
+		if test.err == "" {
+			if err != nil || config == nil {
+				t.Errorf("%s: unexpected error: %v", test.name, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: got %v, want '%s'", test.name, err, test.err)
+		}
+		if problems := Lint([]byte(test.config), ""); len(problems) == 0 {
+			t.Errorf("%s: rejected but lint passes", test.name)
+		}
+	}
+}
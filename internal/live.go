@@ -0,0 +1,192 @@
+package internal
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// LiveActiveWindow is how recently an in-progress session must have been seen to be active
+	LiveActiveWindow = 30 * time.Minute
+	liveBuffer       = 16
+)
+
+type (
+	// LiveEvent is published whenever a result is recorded
+	LiveEvent struct {
+		Survey    string        `json:"survey"`
+		Tag       string        `json:"tag"`
+		Client    string        `json:"client,omitempty"`
+		Session   string        `json:"session,omitempty"`
+		Mode      string        `json:"mode,omitempty"`
+		Active    int           `json:"active"`
+		Completed int           `json:"completed"`
+		Clients   []*LiveClient `json:"clients"`
+		Summary   *Summary      `json:"summary"`
+	}
+
+	// LiveClient is the latest activity of a (client's) session
+	LiveClient struct {
+		Client  string `json:"client"`
+		Session string `json:"session"`
+		Mode    string `json:"mode"`
+		Last    string `json:"last"`
+		Active  bool   `json:"active"`
+	}
+
+	// Live tracks the latest result of each session so it can be summarized without stitching
+	Live struct {
+		sync.Mutex
+		cfg     *Exports
+		results map[string]*StitchObject
+		clients map[string]*LiveClient
+		names   []string
+	}
+
+	// Broker fans out (json) events to subscribers
+	Broker struct {
+		sync.Mutex
+		subscribers map[chan []byte]bool
+	}
+)
+
+// NewLive creates live tracking for a run config, seeded from the existing results
+func NewLive(store ResultStore, config string) (*Live, error) {
+	b, err := ioutil.ReadFile(config)
+	if err != nil {
+		return nil, err
+	}
+	l := &Live{cfg: &Exports{}, results: make(map[string]*StitchObject), clients: make(map[string]*LiveClient)}
+	if err := json.Unmarshal(b, l.cfg); err != nil {
+		return nil, err
+	}
+	if err := store.All(func(stored *StoredResult) error {
+		l.Record(stored.Client, stored.Session, stored.Mode, stored.Data)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Record applies a new result for a session (a 'save' is only replaced by another 'save')
+func (l *Live) Record(client, session, mode string, data *ResultData) {
+	l.Lock()
+	defer l.Unlock()
+	last := TimeString()
+	if t, ok := data.Datum[TimestampKey]; ok && len(t) > 0 {
+		last = t[0]
+	}
+	c, ok := l.clients[session]
+	if !ok {
+		c = &LiveClient{Session: session}
+		l.clients[session] = c
+		l.names = append(l.names, session)
+	}
+	c.Client = client
+	c.Last = last
+	existing, ok := l.results[session]
+	if ok && existing.status == SaveMode && mode != SaveMode {
+		return
+	}
+	c.Mode = mode
+	l.results[session] = &StitchObject{client: client, status: mode, results: data}
+}
+
+// Matching counts the saved results matching a condition, excluding a session
+func (l *Live) Matching(cond *Condition, exclude string) int {
+	l.Lock()
+	defer l.Unlock()
+	count := 0
+	for session, o := range l.results {
+		if session == exclude || o.status != SaveMode {
+			continue
+		}
+		if cond.Evaluate(o.results.Datum) {
+			count++
+		}
+	}
+	return count
+}
+
+// Event creates an event for the current state
+func (l *Live) Event(survey, tag string) *LiveEvent {
+	event := &LiveEvent{Survey: survey, Tag: tag}
+	result := l.snapshot(event)
+	// NOTE: results are replaced (never changed) by Record, so they are summarized without holding the lock
+	event.Summary = result.summarize(l.cfg)
+	return event
+}
+
+// snapshot copies the current clients into an event, returning their results
+func (l *Live) snapshot(event *LiveEvent) *StitchResult {
+	l.Lock()
+	defer l.Unlock()
+	result := &StitchResult{}
+	names := append([]string{}, l.names...)
+	sort.Strings(names)
+	now := time.Now()
+	for _, name := range names {
+		c := *l.clients[name]
+		if c.Mode == SaveMode {
+			event.Completed++
+		} else if t, err := time.ParseInLocation(timeFormat, c.Last, time.Local); err == nil && now.Sub(t) < LiveActiveWindow {
+			c.Active = true
+			event.Active++
+		}
+		event.Clients = append(event.Clients, &c)
+		result.Objects = append(result.Objects, l.results[name])
+	}
+	return result
+}
+
+// NewBroker creates a new event broker
+func NewBroker() *Broker {
+	return &Broker{subscribers: make(map[chan []byte]bool)}
+}
+
+// Subscribe gets a channel receiving published events
+func (b *Broker) Subscribe() chan []byte {
+	b.Lock()
+	defer b.Unlock()
+	c := make(chan []byte, liveBuffer)
+	b.subscribers[c] = true
+	return c
+}
+
+// Unsubscribe stops (and closes) a subscription
+func (b *Broker) Unsubscribe(c chan []byte) {
+	b.Lock()
+	defer b.Unlock()
+	if _, ok := b.subscribers[c]; ok {
+		delete(b.subscribers, c)
+		close(c)
+	}
+}
+
+// Watched indicates there are subscribers (events need not be created otherwise)
+func (b *Broker) Watched() bool {
+	b.Lock()
+	defer b.Unlock()
+	return len(b.subscribers) > 0
+}
+
+// Publish sends an event to all subscribers (slow subscribers miss events rather than block)
+func (b *Broker) Publish(event interface{}) {
+	datum, err := json.Marshal(event)
+	if err != nil {
+		Error("unable to publish event", err)
+		return
+	}
+	b.Lock()
+	defer b.Unlock()
+	for c := range b.subscribers {
+		select {
+		case c <- datum:
+		default:
+		}
+	}
+}
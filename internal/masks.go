@@ -0,0 +1,181 @@
+package internal
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+)
+
+const (
+	// MaskLength is the length of a client mask (pseudonym)
+	MaskLength = 10
+	// PseudonymLength is the length of a keyed-hash client pseudonym
+	PseudonymLength = 16
+)
+
+type (
+	// Masks are the pseudonyms given to clients, persisted (encrypted) when a key is configured
+	Masks struct {
+		sync.Mutex
+		path    string
+		gcm     cipher.AEAD
+		clients map[string]string
+		known   map[string]bool
+	}
+
+	// MaskEntry is a client and its mask
+	MaskEntry struct {
+		Mask   string
+		Client string
+	}
+
+	maskTable struct {
+		Clients map[string]string `json:"clients"`
+		Known   []string          `json:"known"`
+	}
+)
+
+// LoadMasks reads the mask table at a path (encrypted with a key), masks are only kept in memory without a key
+func LoadMasks(path, key string) (*Masks, error) {
+	m := &Masks{clients: make(map[string]string), known: make(map[string]bool)}
+	if key == "" {
+		return m, nil
+	}
+	hashed := sha256.Sum256([]byte(key))
+	block, err := aes.NewCipher(hashed[:])
+	if err != nil {
+		return nil, err
+	}
+	m.gcm, err = cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	m.path = path
+	if !PathExists(path) {
+		return m, nil
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	size := m.gcm.NonceSize()
+	if len(b) < size {
+		return nil, fmt.Errorf("corrupt mask table")
+	}
+	plain, err := m.gcm.Open(nil, b[0:size], b[size:], nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decrypt mask table (wrong key?)")
+	}
+	table := &maskTable{}
+	if err := json.Unmarshal(plain, table); err != nil {
+		return nil, err
+	}
+	for client, mask := range table.Clients {
+		m.clients[client] = mask
+		m.known[mask] = true
+	}
+	for _, mask := range table.Known {
+		m.known[mask] = true
+	}
+	return m, nil
+}
+
+// Pseudonym derives the (stable) pseudonym of a client with a key, nothing is stored to map it back
+func Pseudonym(key, client string) string {
+	h := hmac.New(sha256.New, []byte(key))
+	h.Write([]byte(client))
+	return hex.EncodeToString(h.Sum(nil))[0:PseudonymLength]
+}
+
+// Persisted indicates the masks are kept across restarts
+func (m *Masks) Persisted() bool {
+	return m.gcm != nil
+}
+
+// Mask gets the mask of a client (a new, unique, one when not known), purging forgets the client (the mask is never reused)
+func (m *Masks) Mask(client string, purge bool) (string, error) {
+	m.Lock()
+	defer m.Unlock()
+	mask, ok := m.clients[client]
+	changed := purge
+	if !ok {
+		for {
+			mask = NewSession(MaskLength)
+			if !m.known[mask] {
+				break
+			}
+		}
+		m.known[mask] = true
+		m.clients[client] = mask
+		changed = true
+	}
+	if purge {
+		delete(m.clients, client)
+	}
+	if changed {
+		if err := m.save(); err != nil {
+			return mask, err
+		}
+	}
+	return mask, nil
+}
+
+// Client gets the client of a mask
+func (m *Masks) Client(mask string) (string, bool) {
+	m.Lock()
+	defer m.Unlock()
+	for client, v := range m.clients {
+		if v == mask {
+			return client, true
+		}
+	}
+	return "", false
+}
+
+// Entries gets the (known) client masks sorted by mask
+func (m *Masks) Entries() []MaskEntry {
+	m.Lock()
+	defer m.Unlock()
+	var entries []MaskEntry
+	for client, mask := range m.clients {
+		entries = append(entries, MaskEntry{Mask: mask, Client: client})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Mask < entries[j].Mask
+	})
+	return entries
+}
+
+func (m *Masks) save() error {
+	if m.gcm == nil {
+		return nil
+	}
+	table := &maskTable{Clients: m.clients}
+	for mask := range m.known {
+		table.Known = append(table.Known, mask)
+	}
+	sort.Strings(table.Known)
+	plain, err := json.Marshal(table)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, m.gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	// NOTE: written aside and renamed so a failed write never loses the table
+	tmp := m.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, m.gcm.Seal(nonce, nonce, plain, nil), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, m.path)
+}
@@ -0,0 +1,13 @@
+//go:build !sqlite
+// +build !sqlite
+
+package internal
+
+import (
+	"fmt"
+)
+
+// NOTE: the sqlite backend needs cgo, it is only built with '-tags sqlite'
+func openSQLiteStore(path, tag string) (ResultStore, error) {
+	return nil, fmt.Errorf("sqlite storage is not supported by this build (build with -tags sqlite): %s", path)
+}
@@ -0,0 +1,54 @@
+package internal
+
+import (
+	"regexp"
+	"strings"
+)
+
+const (
+	pipeSep = ", "
+)
+
+var (
+	// NOTE: matches {{answer "key"}} (the client-side equivalent is in survey.js)
+	pipeRef = regexp.MustCompile(`\{\{\s*answer\s+"([^"]*)"\s*\}\}`)
+)
+
+// PipeRefs gets the question keys referenced by answer piping in a text
+func PipeRefs(text string) []string {
+	var refs []string
+	for _, m := range pipeRef.FindAllStringSubmatch(text, -1) {
+		refs = append(refs, m[1])
+	}
+	return refs
+}
+
+// Pipe renders the answer references in a text using a set of answers (unanswered references are empty)
+func Pipe(text string, answers map[string][]string) string {
+	if !strings.Contains(text, "{{") {
+		return text
+	}
+	skipped := make(map[string]bool)
+	for _, k := range answers[SkippedKey] {
+		skipped[k] = true
+	}
+	return pipeRef.ReplaceAllStringFunc(text, func(ref string) string {
+		key := pipeRef.FindStringSubmatch(ref)[1]
+		if skipped[key] {
+			return ""
+		}
+		var values []string
+		for _, v := range answers[key] {
+			if strings.TrimSpace(v) != "" {
+				values = append(values, strings.TrimSpace(v))
+			}
+		}
+		return strings.Join(values, pipeSep)
+	})
+}
+
+// Render pipes answers into the displayed text and description of the field
+func (f *Field) Render(answers map[string][]string) {
+	f.Rendered = Pipe(f.Text, answers)
+	f.RenderedDescription = Pipe(f.Description, answers)
+}
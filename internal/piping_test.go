@@ -0,0 +1,51 @@
+package internal
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPipe(t *testing.T) {
+	answers := map[string][]string{
+		"name":     {" Ada "},
+		"colors":   {"red", "", "blue"},
+		"hidden":   {"secret"},
+		"blank":    {" "},
+		SkippedKey: {"hidden"},
+	}
+	tests := []struct {
+		text   string
+		expect string
+	}{
+		{"no references", "no references"},
+		{`Hello {{answer "name"}}!`, "Hello Ada!"},
+		{`{{ answer  "name" }}`, "Ada"},
+		{`You like {{answer "colors"}}`, "You like red, blue"},
+		{`Hidden: {{answer "hidden"}}.`, "Hidden: ."},
+		{`Blank: {{answer "blank"}}.`, "Blank: ."},
+		{`Missing: {{answer "missing"}}.`, "Missing: ."},
+		{`{{answer "name"}} and {{answer "name"}}`, "Ada and Ada"},
+		{`{{other "name"}}`, `{{other "name"}}`},
+	}
+	for _, test := range tests {
+		if got := Pipe(test.text, answers); got != test.expect {
+			t.Errorf("%s: got '%s', want '%s'", test.text, got, test.expect)
+		}
+	}
+}
+
+func TestPipeRefs(t *testing.T) {
+	tests := []struct {
+		text   string
+		expect []string
+	}{
+		{"plain", nil},
+		{`{{answer "a"}} {{ answer "b_2" }}`, []string{"a", "b_2"}},
+		{`{{answer a}}`, nil},
+	}
+	for _, test := range tests {
+		if got := PipeRefs(test.text); strings.Join(got, ",") != strings.Join(test.expect, ",") {
+			t.Errorf("%s: got %v, want %v", test.text, got, test.expect)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package internal
+
+import (
+	"fmt"
+)
+
+const (
+	// ClosedKey reports a submission rejected because the survey (or a quota) is closed
+	ClosedKey = "closed"
+	// ClosedMessage is shown when a survey is closed and no message is configured
+	ClosedMessage = "This survey is closed, thank you for your interest"
+)
+
+type (
+	// Quota limits the number of completed results matching a condition
+	Quota struct {
+		When  string `json:"when"`
+		Max   int    `json:"max"`
+		Count int    `json:"count"`
+		cond  *Condition
+	}
+)
+
+// NewQuota parses a quota definition
+func NewQuota(cfg QuotaConfig) (*Quota, error) {
+	if cfg.Max <= 0 {
+		return nil, fmt.Errorf("quota '%s' must have a max greater than 0", cfg.When)
+	}
+	cond, err := ParseCondition(cfg.When, false)
+	if err != nil {
+		return nil, err
+	}
+	return &Quota{When: cfg.When, Max: cfg.Max, cond: cond}, nil
+}
+
+// Condition gets the condition results are counted against
+func (q *Quota) Condition() *Condition {
+	return q.cond
+}
+
+// Matches checks if a set of answers counts towards the quota
+func (q *Quota) Matches(answers map[string][]string) bool {
+	return q.cond.Evaluate(answers)
+}
+
+// Full indicates the quota has been reached
+func (q *Quota) Full() bool {
+	return q.Count >= q.Max
+}
+
+// Counted copies the quota with the number of completed (live) results matching it, excluding a session (whose result would be replaced)
+func (q *Quota) Counted(live *Live, exclude string) *Quota {
+	counted := *q
+	counted.Count = live.Matching(q.cond, exclude)
+	return &counted
+}
+
+// Completed counts the saved results in a manifest, excluding a session (whose result would be replaced)
+func (manifest *Manifest) Completed(exclude string) int {
+	count := 0
+	for idx, mode := range manifest.Modes {
+		if mode == SaveMode && manifest.Sessions[idx] != exclude {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,88 @@
+package internal
+
+import (
+	"testing"
+)
+
+func TestNewQuota(t *testing.T) {
+	tests := []struct {
+		cfg   QuotaConfig
+		valid bool
+	}{
+		{QuotaConfig{When: "role == Student", Max: 10}, true},
+		{QuotaConfig{When: "role == Student", Max: 0}, false},
+		{QuotaConfig{When: "role Student", Max: 10}, false},
+	}
+	for _, test := range tests {
+		_, err := NewQuota(test.cfg)
+		if (err == nil) != test.valid {
+			t.Errorf("%v: got error %v, want valid %v", test.cfg, err, test.valid)
+		}
+	}
+}
+
+func TestQuotaCounted(t *testing.T) {
+	live := &Live{results: make(map[string]*StitchObject), clients: make(map[string]*LiveClient)}
+	for _, r := range []struct {
+		session string
+		mode    string
+		role    string
+	}{
+		{"s1", SaveMode, "Student"},
+		{"s2", SaveMode, "Student"},
+		{"s3", SnapshotMode, "Student"},
+		{"s4", SaveMode, "Staff"},
+		{"s5", SaveMode, "Student"},
+		{"s5", SnapshotMode, "Staff"},
+	} {
+		live.Record("client", r.session, r.mode, &ResultData{Datum: map[string][]string{"role": {r.role}}})
+	}
+	tests := []struct {
+		when    string
+		max     int
+		exclude string
+		count   int
+		full    bool
+	}{
+		{"role == Student", 3, "", 3, true},
+		{"role == Student", 4, "", 3, false},
+		{"role == Student", 3, "s1", 2, false},
+		{"role == Staff", 1, "", 1, true},
+		{"role == Staff", 1, "s4", 0, false},
+	}
+	for _, test := range tests {
+		q, err := NewQuota(QuotaConfig{When: test.when, Max: test.max})
+		if err != nil {
+			t.Fatal(err)
+		}
+		counted := q.Counted(live, test.exclude)
+		if counted.Count != test.count || counted.Full() != test.full {
+			t.Errorf("%s (max %d, excluding '%s'): got count %d, full %v", test.when, test.max, test.exclude, counted.Count, counted.Full())
+		}
+		if q.Count != 0 {
+			t.Errorf("%s: counting changed the quota", test.when)
+		}
+	}
+}
+
+func TestCompleted(t *testing.T) {
+	m := &Manifest{}
+	m.Update("c1", "s1", ManifestResult{File: "t_2020-01-01T00-00-00_save_c1_x_s1", Mode: SaveMode})
+	m.Update("c2", "s2", ManifestResult{File: "t_2020-01-01T00-00-01_snapshot_c2_x_s2", Mode: SnapshotMode})
+	m.Update("c3", "s3", ManifestResult{File: "t_2020-01-01T00-00-02_save_c3_x_s3", Mode: SaveMode})
+	m.Update("c3", "s3", ManifestResult{File: "t_2020-01-01T00-00-03_snapshot_c3_x_s3", Mode: SnapshotMode})
+	tests := []struct {
+		exclude string
+		count   int
+	}{
+		{"", 2},
+		{"s1", 1},
+		{"s2", 2},
+		{"other", 2},
+	}
+	for _, test := range tests {
+		if got := m.Completed(test.exclude); got != test.count {
+			t.Errorf("excluding '%s': got %d, want %d", test.exclude, got, test.count)
+		}
+	}
+}
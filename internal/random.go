@@ -0,0 +1,102 @@
+package internal
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+)
+
+const (
+	groupSalt = "group:"
+)
+
+// SessionRand creates a random source seeded from a session (and a salt, e.g. a question key) so a session always sees the same order
+func SessionRand(session, salt string) *rand.Rand {
+	h := fnv.New64a()
+	h.Write([]byte(session))
+	h.Write([]byte{0})
+	h.Write([]byte(salt))
+	return rand.New(rand.NewSource(int64(h.Sum64())))
+}
+
+// Present orders fields (and their options) as they are presented to a session
+func Present(fields []Field, session string) []Field {
+	presented := make([]Field, len(fields))
+	copy(presented, fields)
+	for idx := range presented {
+		f := &presented[idx]
+		if !f.Randomize {
+			continue
+		}
+		opts := make([]string, len(f.Options))
+		copy(opts, f.Options)
+		SessionRand(session, f.Key).Shuffle(len(opts), func(i, j int) {
+			opts[i], opts[j] = opts[j], opts[i]
+		})
+		f.Options = opts
+	}
+	// NOTE: randomized groups are validated as contiguous when loaded
+	for start := 0; start < len(presented); {
+		group := presented[start].RandomGroup
+		end := start + 1
+		if group != "" {
+			for end < len(presented) && presented[end].RandomGroup == group {
+				end++
+			}
+			run := presented[start:end]
+			SessionRand(session, groupSalt+group).Shuffle(len(run), func(i, j int) {
+				run[i], run[j] = run[j], run[i]
+			})
+		}
+		start = end
+	}
+	return presented
+}
+
+// Presented gets the answer entries recording what a session was presented: option orders by question and the question order (when groups are randomized)
+func Presented(fields []Field, session string) map[string][]string {
+	values := make(map[string][]string)
+	grouped := false
+	var order []string
+	for _, f := range Present(fields, session) {
+		if f.Randomize {
+			values[PresentedKey+"."+f.Key] = f.Options
+		}
+		if f.RandomGroup != "" {
+			grouped = true
+		}
+		order = append(order, f.Key)
+	}
+	if grouped {
+		values[PresentedKey] = order
+	}
+	return values
+}
+
+// CheckRandomGroup checks a randomized group is a contiguous run of (non-control) questions, returning the offending question index (or -1)
+func CheckRandomGroup(questions []Question, group string) (int, error) {
+	first, last := -1, -1
+	for idx, q := range questions {
+		if q.Group != group {
+			continue
+		}
+		if first < 0 {
+			first = idx
+		}
+		last = idx
+	}
+	if first < 0 {
+		return -1, fmt.Errorf("randomized group %s has no questions", group)
+	}
+	for idx := first; idx <= last; idx++ {
+		q := questions[idx]
+		if q.Group != group {
+			return idx, fmt.Errorf("randomized group %s is not contiguous", group)
+		}
+		switch q.Type {
+		case "pagebreak", "conditional":
+			return idx, fmt.Errorf("randomized group %s contains a %s", group, q.Type)
+		}
+	}
+	return -1, nil
+}
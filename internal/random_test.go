@@ -0,0 +1,82 @@
+package internal
+
+import (
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestPresent(t *testing.T) {
+	fields := []Field{
+		{Key: "1", Options: []string{"a", "b", "c", "d", "e"}, Randomize: true},
+		{Key: "2", Options: []string{"x", "y", "z"}},
+		{Key: "3", RandomGroup: "g"},
+		{Key: "4", RandomGroup: "g"},
+		{Key: "5", RandomGroup: "g"},
+		{Key: "6", RandomGroup: "g"},
+		{Key: "7"},
+	}
+	keys := func(presented []Field) string {
+		var k []string
+		for _, f := range presented {
+			k = append(k, f.Key)
+		}
+		return strings.Join(k, ",")
+	}
+	sorted := func(values []string) string {
+		s := append([]string{}, values...)
+		sort.Strings(s)
+		return strings.Join(s, ",")
+	}
+	orders := make(map[string]bool)
+	for _, session := range []string{"s1", "s2", "s3", "s4", "s5", "s6", "s7", "s8"} {
+		presented := Present(fields, session)
+		again := Present(fields, session)
+		if keys(presented) != keys(again) || strings.Join(presented[0].Options, ",") != strings.Join(again[0].Options, ",") {
+			t.Errorf("%s: presentation is not stable", session)
+		}
+		if presented[0].Key != "1" || presented[1].Key != "2" || presented[6].Key != "7" {
+			t.Errorf("%s: questions outside of the group moved: %s", session, keys(presented))
+		}
+		if sorted(presented[0].Options) != "a,b,c,d,e" {
+			t.Errorf("%s: options changed: %v", session, presented[0].Options)
+		}
+		if strings.Join(presented[1].Options, ",") != "x,y,z" {
+			t.Errorf("%s: options shuffled without randomize: %v", session, presented[1].Options)
+		}
+		group := keys(presented[2:6])
+		if sorted(strings.Split(group, ",")) != "3,4,5,6" {
+			t.Errorf("%s: group changed: %s", session, group)
+		}
+		orders[strings.Join(presented[0].Options, ",")+" "+group] = true
+	}
+	if len(orders) < 2 {
+		t.Error("sessions were all presented the same order")
+	}
+	if keys(fields) != "1,2,3,4,5,6,7" || strings.Join(fields[0].Options, ",") != "a,b,c,d,e" {
+		t.Error("presenting changed the question set")
+	}
+}
+
+func TestPresented(t *testing.T) {
+	tests := []struct {
+		name   string
+		fields []Field
+		keys   string
+	}{
+		{"none", []Field{{Key: "1"}}, ""},
+		{"options", []Field{{Key: "1", Options: []string{"a", "b"}, Randomize: true}, {Key: "2"}}, PresentedKey + ".1"},
+		{"group", []Field{{Key: "1", RandomGroup: "g"}, {Key: "2", RandomGroup: "g"}}, PresentedKey},
+	}
+	for _, test := range tests {
+		values := Presented(test.fields, "session")
+		var keys []string
+		for k := range values {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		if strings.Join(keys, ",") != test.keys {
+			t.Errorf("%s: got %v, want %s", test.name, keys, test.keys)
+		}
+	}
+}
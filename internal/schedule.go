@@ -0,0 +1,199 @@
+package internal
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	// ScheduleAuto follows the configured open/close window
+	ScheduleAuto = "auto"
+	// ScheduleOpen keeps a survey open regardless of the window
+	ScheduleOpen = "open"
+	// ScheduleClosed closes a survey (starting the grace period) regardless of the window
+	ScheduleClosed = "closed"
+	// DefaultGrace is how long in-progress sessions may still submit after a survey closes
+	DefaultGrace    = 30 * time.Minute
+	scheduleDisplay = "2006-01-02 15:04"
+)
+
+var (
+	// NOTE: times without a zone are local
+	scheduleLayouts = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02T15:04", scheduleDisplay, "2006-01-02"}
+)
+
+type (
+	// Schedule is the window a survey accepts new sessions (and submissions) in
+	Schedule struct {
+		sync.Mutex
+		opens    time.Time
+		closes   time.Time
+		grace    time.Duration
+		override string
+		closedAt time.Time
+		onClose  func()
+		timer    *time.Timer
+	}
+)
+
+// ParseScheduleTime parses an opens/closes time
+func ParseScheduleTime(value string) (time.Time, error) {
+	for _, layout := range scheduleLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time: %s", value)
+}
+
+// NewSchedule creates the schedule of a survey definition
+func NewSchedule(meta Meta) (*Schedule, error) {
+	s := &Schedule{grace: DefaultGrace, override: ScheduleAuto}
+	var err error
+	if meta.Opens != "" {
+		if s.opens, err = ParseScheduleTime(meta.Opens); err != nil {
+			return nil, err
+		}
+	}
+	if meta.Closes != "" {
+		if s.closes, err = ParseScheduleTime(meta.Closes); err != nil {
+			return nil, err
+		}
+	}
+	if !s.opens.IsZero() && !s.closes.IsZero() && !s.opens.Before(s.closes) {
+		return nil, fmt.Errorf("survey closes (%s) before it opens (%s)", meta.Closes, meta.Opens)
+	}
+	if meta.Grace != "" {
+		if s.grace, err = time.ParseDuration(meta.Grace); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+func (s *Schedule) closing() time.Time {
+	switch s.override {
+	case ScheduleOpen:
+		return time.Time{}
+	case ScheduleClosed:
+		return s.closedAt
+	}
+	return s.closes
+}
+
+// Open indicates new sessions can be started
+func (s *Schedule) Open(now time.Time) bool {
+	s.Lock()
+	defer s.Unlock()
+	if s.override == ScheduleOpen {
+		return true
+	}
+	if !s.opens.IsZero() && now.Before(s.opens) {
+		return false
+	}
+	closes := s.closing()
+	return closes.IsZero() || now.Before(closes)
+}
+
+// Pending indicates the survey has not opened yet
+func (s *Schedule) Pending(now time.Time) bool {
+	s.Lock()
+	defer s.Unlock()
+	return s.override == ScheduleAuto && !s.opens.IsZero() && now.Before(s.opens)
+}
+
+// Accepting indicates (in-progress) sessions can still submit, the grace period follows a close
+func (s *Schedule) Accepting(now time.Time) bool {
+	s.Lock()
+	defer s.Unlock()
+	if s.override == ScheduleOpen {
+		return true
+	}
+	if s.override == ScheduleAuto && !s.opens.IsZero() && now.Before(s.opens) {
+		return false
+	}
+	closes := s.closing()
+	return closes.IsZero() || now.Before(closes.Add(s.grace))
+}
+
+// Override forces the survey open/closed (or back to the configured window)
+func (s *Schedule) Override(state string) error {
+	s.Lock()
+	defer s.Unlock()
+	switch state {
+	case ScheduleAuto, ScheduleOpen:
+	case ScheduleClosed:
+		if s.override != ScheduleClosed {
+			s.closedAt = time.Now()
+		}
+	default:
+		return fmt.Errorf("unknown schedule override: %s", state)
+	}
+	s.override = state
+	s.arm()
+	return nil
+}
+
+// State gets the override state
+func (s *Schedule) State() string {
+	s.Lock()
+	defer s.Unlock()
+	return s.override
+}
+
+// Window describes the configured window
+func (s *Schedule) Window() string {
+	s.Lock()
+	defer s.Unlock()
+	if s.opens.IsZero() && s.closes.IsZero() {
+		return "always open"
+	}
+	opens := "-"
+	if !s.opens.IsZero() {
+		opens = s.opens.Format(scheduleDisplay)
+	}
+	closes := "-"
+	if !s.closes.IsZero() {
+		closes = s.closes.Format(scheduleDisplay)
+	}
+	return fmt.Sprintf("%s to %s (grace %s)", opens, closes, s.grace)
+}
+
+// Opens gets when the survey opens
+func (s *Schedule) Opens() string {
+	return s.opens.Format(scheduleDisplay)
+}
+
+// OnClose runs a function once the survey has closed and the grace period has passed
+func (s *Schedule) OnClose(fn func()) {
+	s.Lock()
+	defer s.Unlock()
+	s.onClose = fn
+	s.arm()
+}
+
+// Stop cancels any pending close function
+func (s *Schedule) Stop() {
+	s.Lock()
+	defer s.Unlock()
+	s.onClose = nil
+	s.arm()
+}
+
+func (s *Schedule) arm() {
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+	closes := s.closing()
+	if s.onClose == nil || closes.IsZero() {
+		return
+	}
+	// NOTE: a close that has already passed (e.g. before a restart) is not run again
+	wait := time.Until(closes.Add(s.grace))
+	if wait < 0 {
+		return
+	}
+	s.timer = time.AfterFunc(wait, s.onClose)
+}
@@ -0,0 +1,92 @@
+package internal
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewSchedule(t *testing.T) {
+	tests := []struct {
+		name  string
+		meta  Meta
+		valid bool
+	}{
+		{"none", Meta{}, true},
+		{"window", Meta{Opens: "2020-01-01 09:00", Closes: "2020-01-02"}, true},
+		{"rfc3339", Meta{Opens: "2020-01-01T09:00:00Z"}, true},
+		{"grace", Meta{Closes: "2020-01-02T10:00", Grace: "1h"}, true},
+		{"invalid time", Meta{Opens: "tomorrow"}, false},
+		{"closes before opens", Meta{Opens: "2020-01-02", Closes: "2020-01-01"}, false},
+		{"closes at open", Meta{Opens: "2020-01-01", Closes: "2020-01-01"}, false},
+		{"invalid grace", Meta{Grace: "soon"}, false},
+	}
+	for _, test := range tests {
+		_, err := NewSchedule(test.meta)
+		if (err == nil) != test.valid {
+			t.Errorf("%s: got error %v, want valid %v", test.name, err, test.valid)
+		}
+	}
+}
+
+func TestSchedule(t *testing.T) {
+	at := func(value string) time.Time {
+		v, err := ParseScheduleTime(value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	window := Meta{Opens: "2020-01-01 09:00", Closes: "2020-01-01 17:00", Grace: "30m"}
+	tests := []struct {
+		name      string
+		meta      Meta
+		override  string
+		now       string
+		open      bool
+		pending   bool
+		accepting bool
+	}{
+		{"always open", Meta{}, ScheduleAuto, "2020-01-01 08:00", true, false, true},
+		{"before opening", window, ScheduleAuto, "2020-01-01 08:59", false, true, false},
+		{"at opening", window, ScheduleAuto, "2020-01-01 09:00", true, false, true},
+		{"in window", window, ScheduleAuto, "2020-01-01 12:00", true, false, true},
+		{"at closing", window, ScheduleAuto, "2020-01-01 17:00", false, false, true},
+		{"in grace", window, ScheduleAuto, "2020-01-01 17:29", false, false, true},
+		{"after grace", window, ScheduleAuto, "2020-01-01 17:30", false, false, false},
+		{"forced open before", window, ScheduleOpen, "2020-01-01 08:00", true, false, true},
+		{"forced open after", window, ScheduleOpen, "2020-01-02 08:00", true, false, true},
+	}
+	for _, test := range tests {
+		s, err := NewSchedule(test.meta)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if err := s.Override(test.override); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		now := at(test.now)
+		if s.Open(now) != test.open || s.Pending(now) != test.pending || s.Accepting(now) != test.accepting {
+			t.Errorf("%s: got open %v, pending %v, accepting %v", test.name, s.Open(now), s.Pending(now), s.Accepting(now))
+		}
+	}
+}
+
+func TestScheduleClosed(t *testing.T) {
+	s, err := NewSchedule(Meta{Grace: "1h"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Override(ScheduleClosed); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	if s.Open(now) || !s.Accepting(now) || s.Accepting(now.Add(2*time.Hour)) {
+		t.Error("closed schedule should only accept submissions in the grace period")
+	}
+	if err := s.Override("paused"); err == nil {
+		t.Error("unknown override accepted")
+	}
+	if s.State() != ScheduleClosed {
+		t.Errorf("unexpected state: %s", s.State())
+	}
+}
@@ -0,0 +1,65 @@
+package internal
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"io/ioutil"
+	"strings"
+)
+
+const (
+	signerKeySize = 32
+	signerSep     = "."
+)
+
+type (
+	// Signer signs (and verifies) values with a server secret, e.g. sessions kept in cookies
+	Signer struct {
+		key []byte
+	}
+)
+
+// LoadSigner reads the secret kept at a path, creating one if none exists
+func LoadSigner(path string) (*Signer, error) {
+	if PathExists(path) {
+		key, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		return &Signer{key: key}, nil
+	}
+	key := make([]byte, signerKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(path, key, 0600); err != nil {
+		return nil, err
+	}
+	return &Signer{key: key}, nil
+}
+
+func (s *Signer) mac(value string) string {
+	h := hmac.New(sha256.New, s.key)
+	h.Write([]byte(value))
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+}
+
+// Sign gets a value with its signature appended
+func (s *Signer) Sign(value string) string {
+	return value + signerSep + s.mac(value)
+}
+
+// Verify gets the value of a signed value (false when the signature does not match)
+func (s *Signer) Verify(signed string) (string, bool) {
+	idx := strings.LastIndex(signed, signerSep)
+	if idx < 0 {
+		return "", false
+	}
+	value := signed[0:idx]
+	if !hmac.Equal([]byte(signed[idx+1:]), []byte(s.mac(value))) {
+		return "", false
+	}
+	return value, true
+}
@@ -0,0 +1,58 @@
+package internal
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSigner(t *testing.T) {
+	s := &Signer{key: []byte("key")}
+	other := &Signer{key: []byte("other")}
+	signed := s.Sign("session.with.dots")
+	tests := []struct {
+		name   string
+		signed string
+		value  string
+		valid  bool
+	}{
+		{"signed", signed, "session.with.dots", true},
+		{"empty value", s.Sign(""), "", true},
+		{"other key", other.Sign("session.with.dots"), "", false},
+		{"tampered value", strings.Replace(signed, "session", "sessiom", 1), "", false},
+		{"tampered signature", signed + "x", "", false},
+		{"unsigned", "session", "", false},
+		{"empty", "", "", false},
+	}
+	for _, test := range tests {
+		value, valid := s.Verify(test.signed)
+		if value != test.value || valid != test.valid {
+			t.Errorf("%s: got '%s' %v, want '%s' %v", test.name, value, valid, test.value, test.valid)
+		}
+	}
+}
+
+func TestLoadSigner(t *testing.T) {
+	dir, err := ioutil.TempDir("", "signer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "secret")
+	created, err := LoadSigner(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadSigner(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value, ok := loaded.Verify(created.Sign("s")); !ok || value != "s" {
+		t.Error("reloaded secret does not verify")
+	}
+	if len(created.key) != signerKeySize {
+		t.Errorf("unexpected key size: %d", len(created.key))
+	}
+}
@@ -0,0 +1,272 @@
+//go:build sqlite
+// +build sqlite
+
+package internal
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	// NOTE: registers the sqlite3 database/sql driver
+	_ "github.com/mattn/go-sqlite3"
+)
+
+const (
+	sqliteSchema = `
+CREATE TABLE IF NOT EXISTS results (
+    seq INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL UNIQUE,
+    client TEXT NOT NULL,
+    session TEXT NOT NULL,
+    mode TEXT NOT NULL,
+    data TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS results_session ON results (session);
+CREATE TABLE IF NOT EXISTS session_manifest (
+    session TEXT PRIMARY KEY,
+    client TEXT NOT NULL,
+    name TEXT NOT NULL,
+    mode TEXT NOT NULL,
+    seq INTEGER NOT NULL
+);`
+	// NOTE: the latest result of a session (results r) is its last 'save' (or last result without one)
+	sqliteLatest = `seq = (
+    SELECT COALESCE(
+        (SELECT MAX(seq) FROM results WHERE session = r.session AND mode = ?),
+        (SELECT MAX(seq) FROM results WHERE session = r.session)))`
+	// NOTE: a 'save' is only replaced by another 'save'
+	sqliteUpdate = `INSERT INTO session_manifest (session, client, name, mode, seq) VALUES (?, ?, ?, ?, ?)
+ON CONFLICT(session) DO UPDATE SET client = excluded.client, name = excluded.name, mode = excluded.mode
+WHERE session_manifest.mode != ? OR excluded.mode = ?`
+)
+
+type (
+	sqliteStore struct {
+		sync.Mutex
+		db   *sql.DB
+		path string
+		tag  string
+	}
+)
+
+func openSQLiteStore(path, tag string) (ResultStore, error) {
+	db, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?_journal_mode=WAL&_synchronous=FULL", path))
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, err
+	}
+	s := &sqliteStore{db: db, path: path, tag: tag}
+	if err := s.migrate(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// migrate rebuilds the (session) manifest of databases indexed by client from their results
+func (s *sqliteStore) migrate() error {
+	var legacy int
+	if err := s.db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'manifest'").Scan(&legacy); err != nil {
+		return err
+	}
+	if legacy == 0 {
+		return nil
+	}
+	Info(fmt.Sprintf("rebuilding index of %s by session", s.path))
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`INSERT OR IGNORE INTO session_manifest (session, client, name, mode, seq)
+SELECT session, client, name, mode, seq FROM results r WHERE `+sqliteLatest, SaveMode); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if _, err := tx.Exec("DROP TABLE manifest"); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *sqliteStore) put(mode, client, session string, data *ResultData) (string, error) {
+	s.Lock()
+	defer s.Unlock()
+	ts := TimeString()
+	if t, ok := data.Datum[TimestampKey]; ok && len(t) > 0 {
+		ts = t[0]
+	}
+	name := fmt.Sprintf("%s_%s_%s_%s", s.tag, ts, mode, CleanName(fmt.Sprintf("%s_%s_%s", client, NewSession(6), session)))
+	datum, err := json.Marshal(data)
+	if err != nil {
+		return "", err
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return "", err
+	}
+	res, err := tx.Exec("INSERT INTO results (name, client, session, mode, data) VALUES (?, ?, ?, ?, ?)", name, client, session, mode, string(datum))
+	if err != nil {
+		tx.Rollback()
+		return "", err
+	}
+	seq, err := res.LastInsertId()
+	if err != nil {
+		tx.Rollback()
+		return "", err
+	}
+	if _, err := tx.Exec(sqliteUpdate, session, client, name, mode, seq, SaveMode, SaveMode); err != nil {
+		tx.Rollback()
+		return "", err
+	}
+	return name, tx.Commit()
+}
+
+func (s *sqliteStore) PutSnapshot(client, session string, data *ResultData) (string, error) {
+	return s.put(SnapshotMode, client, session, data)
+}
+
+func (s *sqliteStore) PutSave(client, session string, data *ResultData) (string, error) {
+	return s.put(SaveMode, client, session, data)
+}
+
+func (s *sqliteStore) Sessions() ([]string, error) {
+	rows, err := s.db.Query("SELECT session FROM session_manifest ORDER BY seq")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var sessions []string
+	for rows.Next() {
+		var sess string
+		if err := rows.Scan(&sess); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, sess)
+	}
+	return sessions, rows.Err()
+}
+
+func decodeResult(datum string) (*ResultData, error) {
+	r := &ResultData{}
+	if err := json.Unmarshal([]byte(datum), r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (s *sqliteStore) LoadSession(session string) (*ResultData, error) {
+	var datum string
+	err := s.db.QueryRow("SELECT data FROM results WHERE session = ? ORDER BY seq DESC LIMIT 1", session).Scan(&datum)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return decodeResult(datum)
+}
+
+func (s *sqliteStore) All(fn func(*StoredResult) error) error {
+	return s.each(fn, "SELECT m.name, m.client, m.session, m.mode, r.data FROM session_manifest m JOIN results r ON r.name = m.name ORDER BY m.seq")
+}
+
+func (s *sqliteStore) Orphans(fn func(*StoredResult) error) error {
+	return s.each(fn, `SELECT name, client, session, mode, data FROM results r
+WHERE session NOT IN (SELECT session FROM session_manifest) AND `+sqliteLatest+" ORDER BY seq", SaveMode)
+}
+
+// each iterates the results selected by a query (name, client, session, mode and data)
+func (s *sqliteStore) each(fn func(*StoredResult) error, query string, args ...interface{}) error {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	var results []*StoredResult
+	for rows.Next() {
+		obj := &StoredResult{}
+		var datum string
+		if err := rows.Scan(&obj.Name, &obj.Client, &obj.Session, &obj.Mode, &datum); err != nil {
+			rows.Close()
+			return err
+		}
+		obj.Data, err = decodeResult(datum)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		results = append(results, obj)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, r := range results {
+		if err := fn(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *sqliteStore) Manifest() *Manifest {
+	m := &Manifest{}
+	rows, err := s.db.Query("SELECT name, client, session, mode FROM session_manifest ORDER BY seq")
+	if err != nil {
+		Error("unable to read manifest", err)
+		return m
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name, client, session, mode string
+		if err := rows.Scan(&name, &client, &session, &mode); err != nil {
+			Error("unable to read manifest entry", err)
+			return m
+		}
+		m.Files = append(m.Files, name)
+		m.Clients = append(m.Clients, client)
+		m.Modes = append(m.Modes, mode)
+		m.Sessions = append(m.Sessions, session)
+	}
+	return m
+}
+
+func (s *sqliteStore) History() *Manifest {
+	m := s.Manifest()
+	index := make(map[string]int)
+	for idx, sess := range m.Sessions {
+		index[sess] = idx
+	}
+	m.History = make([][]ManifestResult, len(m.Sessions))
+	rows, err := s.db.Query("SELECT name, session, mode FROM results ORDER BY seq")
+	if err != nil {
+		Error("unable to read manifest history", err)
+		return m
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name, session, mode string
+		if err := rows.Scan(&name, &session, &mode); err != nil {
+			Error("unable to read manifest history entry", err)
+			return m
+		}
+		if idx, ok := index[session]; ok {
+			m.History[idx] = append(m.History[idx], ManifestResult{File: name, Mode: mode, Timestamp: timestampOf(name)})
+		}
+	}
+	return m
+}
+
+func (s *sqliteStore) Path() string {
+	return s.path
+}
+
+func (s *sqliteStore) Close() error {
+	return s.db.Close()
+}
@@ -1,22 +1,34 @@
 package internal
 
 import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
 	"encoding/csv"
 	"encoding/json"
 	"fmt"
 	"html"
 	"html/template"
+	"io"
 	"io/ioutil"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"sort"
-	"strconv"
 	"strings"
+	"time"
 )
 
 const (
-	templateHTML = `<!doctype html>
+	// ResultsHTML is the output suffix of the per-respondent html results
+	ResultsHTML = ".html"
+	// SummaryHTML is the output suffix of the aggregate (summary) html report
+	SummaryHTML = ".summary.html"
+	// SummaryJSON is the output suffix of the aggregate (summary) json report
+	SummaryJSON    = ".summary.json"
+	bundleInputs   = "inputs"
+	bundleConfig   = "run.config"
+	bundleManifest = "results.index.manifest"
+	templateHTML   = `<!doctype html>
 <html lang="en">
 <style>
 pre{
@@ -28,7 +40,9 @@ pre{
 {{ range $okey, $resp := .Objects }}
 {{ if $resp.Start }}<hr />{{ end }}
 	<h4>{{ $resp.Question }}</h4>
-	<pre>{{ $resp.HTMLResponse }}</pre>
+{{ if $resp.Rendered }}	<p>{{ $resp.Rendered }}</p>
+{{ end }}{{ if $resp.Presented }}	<p>presented: {{ $resp.Presented }}</p>
+{{ end }}	<pre>{{ $resp.HTMLResponse }}</pre>
 {{ if $resp.End }}<hr />{{ end }}
 {{ end }}
 </div>
@@ -43,6 +57,13 @@ type (
 		Config    string
 		Directory string
 		OutName   string
+		// Store is used (when set) instead of reading the manifest/directory
+		Store ResultStore
+		// From/Until limit results to those submitted in [From, Until) (when set)
+		From  string
+		Until string
+		// Orphans includes sessions with results but no index entry (e.g. abandoned or replaced by an older index)
+		Orphans bool
 	}
 
 	// TemplateResult displays/formats for HTML output
@@ -53,6 +74,8 @@ type (
 	// TemplateResponse is an HTML friendly response
 	TemplateResponse struct {
 		Question     string
+		Rendered     string
+		Presented    string
 		HTMLResponse string
 		Start        bool
 		End          bool
@@ -67,6 +90,7 @@ type (
 		File      string `json:"file"`
 		client    string
 		mode      string
+		status    string
 		results   *ResultData
 		Responses []Response `json:"responses"`
 	}
@@ -75,16 +99,27 @@ type (
 	Response struct {
 		Question string `json:"question"`
 		Answer   string `json:"answer"`
+		// Rendered is the question text as shown (when answers were piped into it)
+		Rendered string `json:"rendered,omitempty"`
+		// Presented is the option order as shown (when options are randomized)
+		Presented string `json:"presented,omitempty"`
+	}
+
+	bundleFile struct {
+		name string
+		data []byte
 	}
 
 	fieldData struct {
 		ExportField
-		values []string
-		index  int
+		values    []string
+		presented []string
+		index     int
+		skipped   bool
 	}
 )
 
-func (s *StitchResult) toHTML(file string) error {
+func (s *StitchResult) toHTML(w io.Writer) error {
 	tmpl, err := template.New("t").Parse(templateHTML)
 	if err != nil {
 		return err
@@ -97,46 +132,177 @@ func (s *StitchResult) toHTML(file string) error {
 				Start:        idx == 0,
 				End:          idx == totalResp,
 				Question:     html.EscapeString(r.Question),
+				Rendered:     html.EscapeString(r.Rendered),
+				Presented:    html.EscapeString(r.Presented),
 				HTMLResponse: html.EscapeString(r.Answer),
 			}
 			obj.Objects = append(obj.Objects, resp)
 		}
 	}
-	html, err := os.Create(file)
-	if err != nil {
-		return err
+	return tmpl.Execute(w, obj)
+}
+
+func (s *StitchResult) toCSV(w io.Writer) error {
+	var records [][]string
+	var header []string
+	for idx, obj := range s.Objects {
+		var responses []string
+		for _, resp := range obj.Responses {
+			if idx == 0 {
+				header = append(header, resp.Question)
+			}
+			responses = append(responses, resp.Answer)
+		}
+		if len(header) > 0 {
+			records = append(records, header)
+			header = []string{}
+		}
+		records = append(records, responses)
 	}
-	defer html.Close()
-	return tmpl.Execute(html, obj)
+	return csv.NewWriter(w).WriteAll(records)
 }
 
-func (f *fieldData) display() string {
-	return fmt.Sprintf("%02d. %s (%s)", f.index, f.Text, f.Type)
+// displayOnly are question types which never carry answers
+var displayOnly = map[string]bool{
+	"label":     true,
+	"hr":        true,
+	"image":     true,
+	"audio":     true,
+	"video":     true,
+	"pagebreak": true,
 }
 
-func (i Inputs) build(index int, m *Manifest, cfg *Exports) (*StitchObject, error) {
-	o := &StitchObject{
-		File:   m.Files[index],
-		client: m.Clients[index],
-		mode:   m.Modes[index],
+func (s *StitchResult) toXLSX(w io.Writer, cfg *Exports) error {
+	results := &xlsxSheet{name: "results"}
+	questions := &xlsxSheet{name: "questions"}
+	header := []xlsxCell{{value: ClientKey}, {value: ModeKey}, {value: SessionKey}, {value: TimestampKey}, {value: PresentedKey}}
+	questions.add(xlsxCell{value: "column"}, xlsxCell{value: "key"}, xlsxCell{value: "text"}, xlsxCell{value: "type"}, xlsxCell{value: "required"}, xlsxCell{value: "options"})
+	type column struct {
+		key    string
+		option string
+		pos    int
+		number bool
+	}
+	var columns []column
+	for idx, f := range cfg.Fields {
+		// NOTE: a conditional without text only closes a block
+		if displayOnly[f.Type] || (f.Type == "conditional" && f.Text == "") {
+			continue
+		}
+		key := f.AnswerKey(idx)
+		disp := (&fieldData{ExportField: *f, index: idx}).display()
+		first := xlsxColumn(len(header))
+		switch {
+		case f.Type == "multiselect" && len(f.Options) > 0:
+			for _, opt := range f.Options {
+				header = append(header, xlsxCell{value: fmt.Sprintf("%s [%s]", disp, opt)})
+				columns = append(columns, column{key: key, option: opt, number: true})
+			}
+		case f.Type == "multiselect" || f.Type == "order":
+			width := len(f.Options)
+			for _, o := range s.Objects {
+				if l := len(o.results.Datum[key]); l > width {
+					width = l
+				}
+			}
+			if width == 0 {
+				width = 1
+			}
+			for pos := 0; pos < width; pos++ {
+				header = append(header, xlsxCell{value: fmt.Sprintf("%s [%d]", disp, pos+1)})
+				columns = append(columns, column{key: key, pos: pos + 1})
+			}
+		default:
+			header = append(header, xlsxCell{value: disp})
+			columns = append(columns, column{key: key, number: f.Type == "number" || f.Type == "slide" || f.Type == "uslide"})
+		}
+		span := first
+		if last := xlsxColumn(len(header) - 1); last != first {
+			span = fmt.Sprintf("%s:%s", first, last)
+		}
+		required := ""
+		if f.Required {
+			required = "yes"
+		}
+		questions.add(xlsxCell{value: span}, xlsxCell{value: key}, xlsxCell{value: f.Text}, xlsxCell{value: f.Type}, xlsxCell{value: required}, xlsxCell{value: strings.Join(f.Options, "\n")})
 	}
-	p := filepath.Join(i.Directory, fmt.Sprintf("%s.json", o.File))
-	if !PathExists(p) {
-		return nil, fmt.Errorf("invalid manifest file request %s", p)
+	results.add(header...)
+	for _, o := range s.Objects {
+		datum := o.results.Datum
+		skipped := make(map[string]bool)
+		for _, k := range datum[SkippedKey] {
+			skipped[k] = true
+		}
+		row := []xlsxCell{{value: o.client}, {value: o.status}, {value: strings.Join(datum[SessionKey], " ")}, {value: strings.Join(datum[TimestampKey], " ")}, {value: presentedText(datum)}}
+		for _, c := range columns {
+			var values []string
+			for _, v := range datum[c.key] {
+				if strings.TrimSpace(v) != "" {
+					values = append(values, v)
+				}
+			}
+			cell := xlsxCell{number: c.number}
+			switch {
+			case skipped[c.key]:
+				cell = xlsxCell{value: "[skipped]"}
+			case c.option != "":
+				if inOptions(c.option, values) {
+					cell.value = "1"
+				}
+			case c.pos > 0:
+				if c.pos <= len(values) {
+					cell.value = values[c.pos-1]
+				}
+			default:
+				cell.value = strings.Join(values, "\n")
+			}
+			row = append(row, cell)
+		}
+		results.add(row...)
 	}
-	b, err := ioutil.ReadFile(p)
-	if err != nil {
-		return nil, err
+	return writeXLSX(w, results, questions)
+}
+
+// presentedText describes what a respondent was presented (question order, then option orders by key)
+func presentedText(datum map[string][]string) string {
+	var lines []string
+	if order, ok := datum[PresentedKey]; ok {
+		lines = append(lines, fmt.Sprintf("order: %s", strings.Join(order, pipeSep)))
+	}
+	var keys []string
+	for k := range datum {
+		if strings.HasPrefix(k, PresentedKey+".") {
+			keys = append(keys, k)
+		}
 	}
-	r := &ResultData{}
-	if err := json.Unmarshal(b, &r); err != nil {
-		return nil, err
+	sort.Strings(keys)
+	for _, k := range keys {
+		lines = append(lines, fmt.Sprintf("%s: %s", strings.TrimPrefix(k, PresentedKey+"."), strings.Join(datum[k], pipeSep)))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (f *fieldData) display() string {
+	return fmt.Sprintf("%02d. %s (%s)", f.index, f.Text, f.Type)
+}
+
+func (i Inputs) build(stored *StoredResult, cfg *Exports) (*StitchObject, error) {
+	o := &StitchObject{
+		File:   stored.Name,
+		client: stored.Client,
+		mode:   stored.Mode,
+		status: stored.Mode,
 	}
+	r := stored.Data
 	o.results = r
 	var fieldNames []string
 	responses := make(map[string]*fieldData)
-	var timestamp []string
-	var session []string
+	skipped := make(map[string]bool)
+	for _, k := range r.Datum[SkippedKey] {
+		skipped[k] = true
+	}
+	session := r.Datum[SessionKey]
+	timestamp := r.Datum[TimestampKey]
 	actualMode := []string{fmt.Sprintf("mode:%s", o.mode)}
 	for cfgIdx, obj := range cfg.Fields {
 		data := &fieldData{
@@ -144,31 +310,23 @@ func (i Inputs) build(index int, m *Manifest, cfg *Exports) (*StitchObject, erro
 		}
 		data.Text = obj.Text
 		data.Type = obj.Type
-		for k, v := range r.Datum {
-			switch k {
-			case ClientKey:
-				continue
-			case SessionKey:
-				session = v
-				continue
-			case TimestampKey:
-				timestamp = v
-				continue
-			}
-			i, err := strconv.Atoi(k)
-			if err != nil {
-				return nil, err
-			}
-			if cfgIdx == i {
-				data.values = v
-			}
-		}
+		// NOTE: answers are matched by key so reordered questions still line up
+		key := obj.AnswerKey(cfgIdx)
+		data.values = r.Datum[key]
+		data.skipped = skipped[key]
+		data.presented = r.Datum[PresentedKey+"."+key]
 		disp := data.display()
 		fieldNames = append(fieldNames, disp)
 		responses[disp] = data
 	}
 	actualMode = append(actualMode, fmt.Sprintf("session:%v", session))
 	actualMode = append(actualMode, fmt.Sprintf("timestamp:%v", timestamp))
+	if order, ok := r.Datum[PresentedKey]; ok {
+		actualMode = append(actualMode, fmt.Sprintf("presented:%v", order))
+	}
+	if stored.Orphaned {
+		actualMode = append(actualMode, "orphaned:true")
+	}
 	if len(fieldNames) == 0 {
 		return nil, fmt.Errorf("no fields found")
 	}
@@ -178,6 +336,10 @@ func (i Inputs) build(index int, m *Manifest, cfg *Exports) (*StitchObject, erro
 	sort.Strings(fieldNames)
 	responses[ClientKey] = &fieldData{values: []string{o.client}}
 	responses[ModeKey] = &fieldData{values: []string{o.mode}}
+	if participant, ok := r.Datum[ParticipantKey]; ok {
+		fieldNames = append(fieldNames, ParticipantKey)
+		responses[ParticipantKey] = &fieldData{values: participant}
+	}
 	for _, f := range fieldNames {
 		var useData []string
 		data := ""
@@ -191,110 +353,221 @@ func (i Inputs) build(index int, m *Manifest, cfg *Exports) (*StitchObject, erro
 		if len(useData) > 0 {
 			data = strings.Join(useData, "\n")
 		}
-		o.Responses = append(o.Responses, Response{
+		if responses[f].skipped {
+			data = "[skipped]"
+		}
+		resp := Response{
 			Question: f,
 			Answer:   data,
-		})
+		}
+		if rendered := Pipe(responses[f].Text, r.Datum); rendered != responses[f].Text {
+			resp.Rendered = rendered
+		}
+		resp.Presented = strings.Join(responses[f].presented, pipeSep)
+		o.Responses = append(o.Responses, resp)
 	}
 	return o, nil
 }
 
-func (i Inputs) save(results StitchResult) error {
-	b, err := json.MarshalIndent(results, "", "  ")
+// outputs renders the stitched results in each output format
+func (s *StitchResult) outputs(name string, cfg *Exports) ([]*bundleFile, error) {
+	b, err := json.MarshalIndent(s, "", "  ")
 	if err != nil {
-		return err
-	}
-	jFile := fmt.Sprintf("%s.json", i.OutName)
-	hFile := fmt.Sprintf("%s.html", i.OutName)
-	cFile := fmt.Sprintf("%s.csv", i.OutName)
-	if err := ioutil.WriteFile(jFile, b, 0644); err != nil {
-		return err
+		return nil, err
 	}
-	if err := results.toHTML(hFile); err != nil {
-		return err
+	var h, c, x, sh bytes.Buffer
+	if err := s.toHTML(&h); err != nil {
+		return nil, err
 	}
-	csvFile, err := os.Create(cFile)
+	summary := s.summarize(cfg)
+	sj, err := json.MarshalIndent(summary, "", "  ")
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer csvFile.Close()
-	var records [][]string
-	var header []string
-	for idx, obj := range results.Objects {
-		var responses []string
-		for _, resp := range obj.Responses {
-			if idx == 0 {
-				header = append(header, resp.Question)
-			}
-			responses = append(responses, resp.Answer)
+	if err := summary.toHTML(&sh); err != nil {
+		return nil, err
+	}
+	if err := s.toCSV(&c); err != nil {
+		return nil, err
+	}
+	if err := s.toXLSX(&x, cfg); err != nil {
+		return nil, err
+	}
+	return []*bundleFile{
+		{name: name + ResultsHTML, data: h.Bytes()},
+		{name: name + ".csv", data: c.Bytes()},
+		{name: name + ".json", data: b},
+		{name: name + ".xlsx", data: x.Bytes()},
+		{name: name + SummaryHTML, data: sh.Bytes()},
+		{name: name + SummaryJSON, data: sj},
+	}, nil
+}
+
+// writeBundle streams files as a gzip'd tar
+func writeBundle(w io.Writer, files []*bundleFile) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	now := time.Now()
+	for _, f := range files {
+		hdr := &tar.Header{
+			Name:    f.name,
+			Mode:    0644,
+			Size:    int64(len(f.data)),
+			ModTime: now,
 		}
-		if len(header) > 0 {
-			records = append(records, header)
-			header = []string{}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := tw.Write(f.data); err != nil {
+			return err
 		}
-		records = append(records, responses)
 	}
-	writer := csv.NewWriter(csvFile)
-	defer writer.Flush()
-	if err := writer.WriteAll(records); err != nil {
+	if err := tw.Close(); err != nil {
 		return err
 	}
-	args := []string{"czvf", fmt.Sprintf("%s.tar.gz", filepath.Base(i.OutName))}
-	for _, f := range []string{hFile, cFile, jFile} {
-		args = append(args, filepath.Base(f))
-	}
-	cmd := exec.Command("tar", args...)
-	cmd.Dir = filepath.Dir(i.OutName)
-	return cmd.Run()
+	return gz.Close()
 }
 
-// Process performs actual stitching
-func (i Inputs) Process() error {
-	for _, p := range []string{i.Manifest, i.Config, i.Directory} {
+// result stitches the results of the inputs, with the run config and the (raw) sources used
+func (i Inputs) result() (*StitchResult, *Exports, []*bundleFile, error) {
+	required := []string{i.Config}
+	if i.Store == nil {
+		required = append(required, i.Manifest, i.Directory)
+	}
+	for _, p := range required {
 		if !PathExists(p) {
-			return fmt.Errorf("missing required argument")
+			return nil, nil, nil, fmt.Errorf("missing required argument")
 		}
 	}
 	if len(i.OutName) == 0 {
-		return fmt.Errorf("invalid output name information")
+		return nil, nil, nil, fmt.Errorf("invalid output name information")
+	}
+	store := i.Store
+	if store == nil {
+		s, err := OpenStitchStore(i.Manifest, i.Directory)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		defer s.Close()
+		store = s
 	}
-	b, err := ioutil.ReadFile(i.Manifest)
+	b, err := ioutil.ReadFile(i.Config)
 	if err != nil {
-		return err
+		return nil, nil, nil, err
 	}
-	m := &Manifest{}
-	if err := json.Unmarshal(b, &m); err != nil {
-		return err
+	cfg := &Exports{}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return nil, nil, nil, err
+	}
+	sources := []*bundleFile{{name: filepath.Join(bundleInputs, bundleConfig), data: b}}
+	// NOTE: results are the latest of each session, a client (e.g. a shared kiosk) can have many
+	sessions := make(map[string]*StitchObject)
+	var sessionNames []string
+	add := func(stored *StoredResult) error {
+		if !i.within(stored) {
+			return nil
+		}
+		o, err := i.build(stored, cfg)
+		if err != nil {
+			return err
+		}
+		raw, err := json.Marshal(stored.Data)
+		if err != nil {
+			return err
+		}
+		sources = append(sources, &bundleFile{name: filepath.Join(bundleInputs, stored.Name+resultExt), data: raw})
+		if _, ok := sessions[stored.Session]; !ok {
+			sessionNames = append(sessionNames, stored.Session)
+		}
+		sessions[stored.Session] = o
+		return nil
+	}
+	if err := store.All(add); err != nil {
+		return nil, nil, nil, err
+	}
+	if i.Orphans {
+		if err := store.Orphans(func(stored *StoredResult) error {
+			stored.Orphaned = true
+			return add(stored)
+		}); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	if len(sessionNames) == 0 {
+		return nil, nil, nil, fmt.Errorf("no objects found")
+	}
+	manifest, err := json.Marshal(store.History())
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	sources = append(sources, &bundleFile{name: filepath.Join(bundleInputs, bundleManifest), data: manifest})
+	sort.SliceStable(sessionNames, func(x, y int) bool {
+		return sessions[sessionNames[x]].client < sessions[sessionNames[y]].client
+	})
+	overall := StitchResult{}
+	for _, name := range sessionNames {
+		overall.Objects = append(overall.Objects, sessions[name])
+	}
+	return &overall, cfg, sources, nil
+}
+
+// stitch renders the outputs of the inputs, returning them and the inputs they came from
+func (i Inputs) stitch() ([]*bundleFile, []*bundleFile, error) {
+	overall, cfg, sources, err := i.result()
+	if err != nil {
+		return nil, nil, err
 	}
-	if err := m.Check(); err != nil {
+	outputs, err := overall.outputs(filepath.Base(i.OutName), cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	return outputs, sources, nil
+}
+
+// Bundle streams the stitched results (and their inputs) as a gzip'd tar
+func (i Inputs) Bundle(w io.Writer) error {
+	outputs, sources, err := i.stitch()
+	if err != nil {
 		return err
 	}
-	b, err = ioutil.ReadFile(i.Config)
+	return writeBundle(w, append(outputs, sources...))
+}
+
+// Render writes a stitched output (by suffix, e.g. ResultsHTML)
+func (i Inputs) Render(w io.Writer, output string) error {
+	outputs, _, err := i.stitch()
 	if err != nil {
 		return err
 	}
-	cfg := &Exports{}
-	if err := json.Unmarshal(b, &cfg); err != nil {
+	name := filepath.Base(i.OutName) + output
+	for _, o := range outputs {
+		if o.name == name {
+			_, err := w.Write(o.data)
+			return err
+		}
+	}
+	return fmt.Errorf("no %s output", output)
+}
+
+// Process performs actual stitching
+func (i Inputs) Process() error {
+	outputs, sources, err := i.stitch()
+	if err != nil {
 		return err
 	}
-	clients := make(map[string]*StitchObject)
-	var clientNames []string
-	for idx := range m.Files {
-		o, err := i.build(idx, m, cfg)
-		if err != nil {
+	dir := filepath.Dir(i.OutName)
+	for _, o := range outputs {
+		if err := ioutil.WriteFile(filepath.Join(dir, o.name), o.data, 0644); err != nil {
 			return err
 		}
-		clients[o.client] = o
-		clientNames = append(clientNames, o.client)
 	}
-	if len(clientNames) == 0 {
-		return fmt.Errorf("no objects found")
+	f, err := os.Create(fmt.Sprintf("%s.tar.gz", i.OutName))
+	if err != nil {
+		return err
 	}
-	sort.Strings(clientNames)
-	overall := StitchResult{}
-	for _, name := range clientNames {
-		o, _ := clients[name]
-		overall.Objects = append(overall.Objects, o)
+	defer f.Close()
+	if err := writeBundle(f, append(outputs, sources...)); err != nil {
+		return err
 	}
-	return i.save(overall)
+	return f.Sync()
 }
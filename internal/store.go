@@ -0,0 +1,266 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const (
+	// FileStoreBackend stores results as json files (plus an index)
+	FileStoreBackend = "file"
+	// SQLiteStoreBackend stores results in a sqlite database
+	SQLiteStoreBackend = "sqlite"
+	// SnapshotMode is the mode of an in-progress survey submission
+	SnapshotMode = "snapshot"
+	sqliteExt    = ".results.db"
+)
+
+type (
+	// ResultStore persists survey results
+	ResultStore interface {
+		// PutSnapshot stores an in-progress result, returning the result name
+		PutSnapshot(client, session string, data *ResultData) (string, error)
+		// PutSave stores a final result, returning the result name
+		PutSave(client, session string, data *ResultData) (string, error)
+		// Sessions lists the (indexed) sessions with stored results, in the order of Manifest
+		Sessions() ([]string, error)
+		// LoadSession gets the latest result of a session (nil if none exists)
+		LoadSession(session string) (*ResultData, error)
+		// All iterates the current (indexed) result of each session
+		All(fn func(*StoredResult) error) error
+		// Orphans iterates the latest result of each session with stored results but no index entry
+		Orphans(fn func(*StoredResult) error) error
+		// Manifest gets a copy of the current index (the latest result of each session)
+		Manifest() *Manifest
+		// History gets a copy of the current index with every result of each session
+		History() *Manifest
+		// Path is the on-disk location of the index
+		Path() string
+		// Close releases the store
+		Close() error
+	}
+
+	// StoredResult is an indexed result
+	StoredResult struct {
+		Name    string
+		Client  string
+		Session string
+		Mode    string
+		Data    *ResultData
+		// Orphaned results are stored without an index entry (see ResultStore.Orphans)
+		Orphaned bool
+	}
+
+	fileStore struct {
+		sync.Mutex
+		dir   string
+		tag   string
+		index IndexStore
+	}
+
+	// manifestReader reads (indexed) result files without writing
+	manifestReader struct {
+		dir      string
+		path     string
+		manifest *Manifest
+	}
+)
+
+// OpenResultStore opens the result store for a tag using the given backend
+func OpenResultStore(backend, dir, tag, index string) (ResultStore, error) {
+	switch SetIfEmpty(backend, FileStoreBackend) {
+	case FileStoreBackend:
+		idx, err := OpenIndex(dir, tag, index)
+		if err != nil {
+			return nil, err
+		}
+		return &fileStore{dir: dir, tag: tag, index: idx}, nil
+	case SQLiteStoreBackend:
+		return openSQLiteStore(filepath.Join(dir, fmt.Sprintf("%s%s", tag, sqliteExt)), tag)
+	}
+	return nil, fmt.Errorf("unknown storage backend: %s", backend)
+}
+
+// OpenStitchStore opens a result store (read only) given an index (manifest, log or database) path
+func OpenStitchStore(index, dir string) (ResultStore, error) {
+	if strings.HasSuffix(index, sqliteExt) {
+		return openSQLiteStore(index, "")
+	}
+	m, err := LoadManifest(index)
+	if err != nil {
+		return nil, err
+	}
+	return &manifestReader{dir: dir, path: index, manifest: m}, nil
+}
+
+func readResultFile(dir, name string) (*ResultData, error) {
+	p := filepath.Join(dir, fmt.Sprintf("%s%s", name, resultExt))
+	if !PathExists(p) {
+		return nil, fmt.Errorf("invalid manifest file request %s", p)
+	}
+	b, err := ioutil.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+	r := &ResultData{}
+	if err := json.Unmarshal(b, &r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// readLatest reads the latest result file of a session (nil if the session has none)
+func readLatest(dir, name string) (*ResultData, error) {
+	if name == "" {
+		return nil, nil
+	}
+	return readResultFile(dir, name)
+}
+
+func eachIndexed(dir string, m *Manifest, fn func(*StoredResult) error) error {
+	for idx, name := range m.Files {
+		r, err := readResultFile(dir, name)
+		if err != nil {
+			return err
+		}
+		if err := fn(&StoredResult{Name: name, Client: m.Clients[idx], Session: m.Sessions[idx], Mode: m.Modes[idx], Data: r}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// eachOrphan reads the results of a tag for the sessions missing from an index
+func eachOrphan(dir, tag string, m *Manifest, fn func(*StoredResult) error) error {
+	indexed := make(map[string]bool)
+	for _, s := range m.Sessions {
+		indexed[s] = true
+	}
+	all, err := RebuildManifest(dir, tag)
+	if err != nil {
+		return err
+	}
+	orphaned := &Manifest{}
+	for idx, s := range all.Sessions {
+		if indexed[s] {
+			continue
+		}
+		orphaned.Update(all.Clients[idx], s, ManifestResult{File: all.Files[idx], Mode: all.Modes[idx]})
+	}
+	return eachIndexed(dir, orphaned, fn)
+}
+
+func (s *fileStore) put(mode, client, session string, data *ResultData) (string, error) {
+	s.Lock()
+	defer s.Unlock()
+	name := CleanName(fmt.Sprintf("%s_%s_%s", client, NewSession(6), session))
+	ts := TimeString()
+	if t, ok := data.Datum[TimestampKey]; ok && len(t) > 0 {
+		ts = t[0]
+	}
+	fname := fmt.Sprintf("%s_%s_%s_%s", s.tag, ts, mode, name)
+	j, err := NewFile(s.dir, fname+resultExt)
+	if err != nil {
+		return "", err
+	}
+	defer j.Close()
+	jsonString, err := json.Marshal(data)
+	if err != nil {
+		return "", err
+	}
+	if _, err := j.Write(jsonString); err != nil {
+		return "", err
+	}
+	if err := j.Sync(); err != nil {
+		return "", err
+	}
+	return fname, s.index.Record(client, session, ManifestResult{File: fname, Mode: mode, Timestamp: ts})
+}
+
+func (s *fileStore) PutSnapshot(client, session string, data *ResultData) (string, error) {
+	return s.put(SnapshotMode, client, session, data)
+}
+
+func (s *fileStore) PutSave(client, session string, data *ResultData) (string, error) {
+	return s.put(SaveMode, client, session, data)
+}
+
+func (s *fileStore) Sessions() ([]string, error) {
+	return s.index.Manifest().Sessions, nil
+}
+
+func (s *fileStore) LoadSession(session string) (*ResultData, error) {
+	return readLatest(s.dir, s.index.Latest(session))
+}
+
+func (s *fileStore) All(fn func(*StoredResult) error) error {
+	return eachIndexed(s.dir, s.index.Manifest(), fn)
+}
+
+func (s *fileStore) Orphans(fn func(*StoredResult) error) error {
+	return eachOrphan(s.dir, s.tag, s.index.Manifest(), fn)
+}
+
+func (s *fileStore) Manifest() *Manifest {
+	return s.index.Manifest()
+}
+
+func (s *fileStore) History() *Manifest {
+	return s.index.History()
+}
+
+func (s *fileStore) Path() string {
+	return s.index.Path()
+}
+
+func (s *fileStore) Close() error {
+	return s.index.Close()
+}
+
+func (r *manifestReader) PutSnapshot(client, session string, data *ResultData) (string, error) {
+	return "", fmt.Errorf("result store is read only")
+}
+
+func (r *manifestReader) PutSave(client, session string, data *ResultData) (string, error) {
+	return "", fmt.Errorf("result store is read only")
+}
+
+func (r *manifestReader) Sessions() ([]string, error) {
+	return append([]string{}, r.manifest.Sessions...), nil
+}
+
+func (r *manifestReader) LoadSession(session string) (*ResultData, error) {
+	return readLatest(r.dir, r.manifest.Latest(session))
+}
+
+func (r *manifestReader) All(fn func(*StoredResult) error) error {
+	return eachIndexed(r.dir, r.manifest, fn)
+}
+
+func (r *manifestReader) Orphans(fn func(*StoredResult) error) error {
+	tag := filepath.Base(r.path)
+	for _, ext := range []string{indexLogExt, manifestExt} {
+		tag = strings.TrimSuffix(tag, ext)
+	}
+	return eachOrphan(r.dir, tag, r.manifest, fn)
+}
+
+func (r *manifestReader) Manifest() *Manifest {
+	return r.manifest.copy(false)
+}
+
+func (r *manifestReader) History() *Manifest {
+	return r.manifest.copy(true)
+}
+
+func (r *manifestReader) Path() string {
+	return r.path
+}
+
+func (r *manifestReader) Close() error {
+	return nil
+}
@@ -0,0 +1,54 @@
+package internal
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestStoreSessions(t *testing.T) {
+	tests := []struct {
+		backend string
+		index   string
+	}{
+		{FileStoreBackend, IndexLogBackend},
+		{FileStoreBackend, IndexManifestBackend},
+		{SQLiteStoreBackend, ""},
+	}
+	for _, test := range tests {
+		dir, err := ioutil.TempDir("", "store")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+		store, err := OpenResultStore(test.backend, dir, "t", test.index)
+		if err != nil {
+			if test.backend == SQLiteStoreBackend {
+				t.Logf("skipping %s: %v", test.backend, err)
+				continue
+			}
+			t.Fatal(err)
+		}
+		for _, r := range []struct {
+			session string
+			save    bool
+		}{{"s1", false}, {"s2", false}, {"s1", true}, {"s3", true}} {
+			put := store.PutSnapshot
+			if r.save {
+				put = store.PutSave
+			}
+			if _, err := put("c", r.session, &ResultData{Datum: map[string][]string{"0": {r.session}}}); err != nil {
+				t.Fatalf("%s: %v", test.backend, err)
+			}
+		}
+		sessions, err := store.Sessions()
+		if err != nil {
+			t.Fatalf("%s: %v", test.backend, err)
+		}
+		if got, want := strings.Join(sessions, ","), strings.Join(store.Manifest().Sessions, ","); got != want || len(sessions) != 3 {
+			t.Errorf("%s/%s: got sessions %s, manifest %s", test.backend, test.index, got, want)
+		}
+		store.Close()
+	}
+}
@@ -0,0 +1,331 @@
+package internal
+
+import (
+	"html/template"
+	"io"
+	"math"
+	"sort"
+	"strings"
+)
+
+const (
+	summaryBuckets  = 10
+	templateSummary = `<!doctype html>
+<html lang="en">
+<style>
+table{
+    border-collapse: collapse;
+    margin-bottom: 1em;
+}
+td, th{
+    border: 1px solid #ccc;
+    padding: 0.25em 0.5em;
+    text-align: left;
+}
+</style>
+<body>
+<div>
+<h4>Summary</h4>
+<table>
+    <tr><th>responses</th><td>{{ .Responses }}</td></tr>
+    <tr><th>completed</th><td>{{ .Completed }}</td></tr>
+    <tr><th>snapshot only</th><td>{{ .Snapshots }}</td></tr>
+    <tr><th>completion rate</th><td>{{ printf "%.1f" .CompletionRate }}%</td></tr>
+</table>
+{{ range $qkey, $q := .Questions }}
+<hr />
+<h4>{{ printf "%02d" $q.Index }}. {{ $q.Text }} ({{ $q.Type }})</h4>
+<p>answered: {{ $q.Answered }}, skipped: {{ $q.Skipped }}</p>
+{{ if $q.Options }}
+<table>
+    <tr><th>option</th><th>count</th><th>percent</th></tr>
+    {{ range $okey, $o := $q.Options }}
+    <tr><td>{{ $o.Option }}</td><td>{{ $o.Count }}</td><td>{{ printf "%.1f" $o.Percent }}%</td></tr>
+    {{ end }}
+</table>
+{{ end }}
+{{ if $q.Stats }}
+<table>
+    <tr><th>mean</th><td>{{ printf "%.2f" $q.Stats.Mean }}</td></tr>
+    <tr><th>median</th><td>{{ printf "%.2f" $q.Stats.Median }}</td></tr>
+    <tr><th>min</th><td>{{ printf "%.2f" $q.Stats.Min }}</td></tr>
+    <tr><th>max</th><td>{{ printf "%.2f" $q.Stats.Max }}</td></tr>
+</table>
+<table>
+    <tr><th>range</th><th>count</th></tr>
+    {{ range $bkey, $b := $q.Stats.Histogram }}
+    <tr><td>{{ printf "%.2f" $b.Low }} - {{ printf "%.2f" $b.High }}</td><td>{{ $b.Count }}</td></tr>
+    {{ end }}
+</table>
+{{ end }}
+{{ if $q.Ranks }}
+<table>
+    <tr><th>option</th><th>score</th><th>mean rank</th></tr>
+    {{ range $rkey, $r := $q.Ranks }}
+    <tr><td>{{ $r.Option }}</td><td>{{ $r.Score }}</td><td>{{ printf "%.2f" $r.MeanRank }}</td></tr>
+    {{ end }}
+</table>
+{{ end }}
+{{ end }}
+</div>
+</body>
+</html>`
+)
+
+type (
+	// Summary is the aggregate view of all results
+	Summary struct {
+		Responses      int                `json:"responses"`
+		Completed      int                `json:"completed"`
+		Snapshots      int                `json:"snapshots"`
+		CompletionRate float64            `json:"completion_rate"`
+		Questions      []*QuestionSummary `json:"questions"`
+	}
+
+	// QuestionSummary aggregates the answers to a question
+	QuestionSummary struct {
+		Index    int            `json:"index"`
+		Key      string         `json:"key"`
+		Text     string         `json:"text"`
+		Type     string         `json:"type"`
+		Answered int            `json:"answered"`
+		Skipped  int            `json:"skipped"`
+		Options  []*OptionCount `json:"options,omitempty"`
+		Stats    *NumberStats   `json:"stats,omitempty"`
+		Ranks    []*RankScore   `json:"ranks,omitempty"`
+	}
+
+	// OptionCount is the frequency of an option (or checkbox state)
+	OptionCount struct {
+		Option  string  `json:"option"`
+		Count   int     `json:"count"`
+		Percent float64 `json:"percent"`
+	}
+
+	// NumberStats describes numeric answers
+	NumberStats struct {
+		Mean      float64   `json:"mean"`
+		Median    float64   `json:"median"`
+		Min       float64   `json:"min"`
+		Max       float64   `json:"max"`
+		Histogram []*Bucket `json:"histogram"`
+	}
+
+	// Bucket is a histogram range [Low, High)
+	Bucket struct {
+		Low   float64 `json:"low"`
+		High  float64 `json:"high"`
+		Count int     `json:"count"`
+	}
+
+	// RankScore is the (Borda) aggregate ranking of an option
+	RankScore struct {
+		Option   string  `json:"option"`
+		Score    int     `json:"score"`
+		MeanRank float64 `json:"mean_rank"`
+	}
+)
+
+func percent(count, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(count) * 100 / float64(total)
+}
+
+func newNumberStats(numbers []float64, low, high float64, fixed bool) *NumberStats {
+	// NOTE: results stored before non-finite numbers were rejected may still contain them
+	var values []float64
+	for _, v := range numbers {
+		if !math.IsNaN(v) && !math.IsInf(v, 0) {
+			values = append(values, v)
+		}
+	}
+	if len(values) == 0 {
+		return nil
+	}
+	sort.Float64s(values)
+	stats := &NumberStats{Min: values[0], Max: values[len(values)-1]}
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	stats.Mean = sum / float64(len(values))
+	mid := len(values) / 2
+	stats.Median = values[mid]
+	if len(values)%2 == 0 {
+		stats.Median = (values[mid-1] + values[mid]) / 2
+	}
+	if !fixed {
+		low, high = stats.Min, stats.Max
+	}
+	width := (high - low) / summaryBuckets
+	if width == 0 {
+		stats.Histogram = []*Bucket{{Low: low, High: high, Count: len(values)}}
+		return stats
+	}
+	for b := 0; b < summaryBuckets; b++ {
+		stats.Histogram = append(stats.Histogram, &Bucket{Low: low + float64(b)*width, High: low + float64(b+1)*width})
+	}
+	for _, v := range values {
+		b := int(math.Floor((v - low) / width))
+		if b >= summaryBuckets {
+			b = summaryBuckets - 1
+		}
+		if b < 0 {
+			b = 0
+		}
+		stats.Histogram[b].Count++
+	}
+	return stats
+}
+
+func optionCounts(options []string, counts map[string]int, total int) []*OptionCount {
+	var result []*OptionCount
+	seen := make(map[string]bool)
+	for _, o := range options {
+		seen[o] = true
+		result = append(result, &OptionCount{Option: o, Count: counts[o], Percent: percent(counts[o], total)})
+	}
+	// NOTE: answers outside of the (exported) options are still reported
+	var other []string
+	for o := range counts {
+		if !seen[o] {
+			other = append(other, o)
+		}
+	}
+	sort.Strings(other)
+	for _, o := range other {
+		result = append(result, &OptionCount{Option: o, Count: counts[o], Percent: percent(counts[o], total)})
+	}
+	return result
+}
+
+// bordaRanks scores each option by n-1-position (summed over respondents)
+func bordaRanks(options []string, orders [][]string) []*RankScore {
+	scores := make(map[string]*RankScore)
+	var all []*RankScore
+	add := func(o string) *RankScore {
+		if s, ok := scores[o]; ok {
+			return s
+		}
+		s := &RankScore{Option: o}
+		scores[o] = s
+		all = append(all, s)
+		return s
+	}
+	for _, o := range options {
+		add(o)
+	}
+	ranked := make(map[string]int)
+	for _, order := range orders {
+		n := len(order)
+		for pos, o := range order {
+			s := add(o)
+			s.Score += n - 1 - pos
+			s.MeanRank += float64(pos + 1)
+			ranked[o]++
+		}
+	}
+	for _, s := range all {
+		if ranked[s.Option] > 0 {
+			s.MeanRank = s.MeanRank / float64(ranked[s.Option])
+		}
+	}
+	sort.SliceStable(all, func(i, j int) bool {
+		return all[i].Score > all[j].Score
+	})
+	return all
+}
+
+func (s *StitchResult) summarize(cfg *Exports) *Summary {
+	summary := &Summary{Responses: len(s.Objects)}
+	for _, o := range s.Objects {
+		if o.status == SaveMode {
+			summary.Completed++
+		} else {
+			summary.Snapshots++
+		}
+	}
+	summary.CompletionRate = percent(summary.Completed, summary.Responses)
+	for idx, f := range cfg.Fields {
+		if displayOnly[f.Type] || (f.Type == "conditional" && f.Text == "") {
+			continue
+		}
+		key := f.AnswerKey(idx)
+		q := &QuestionSummary{Index: idx, Key: key, Text: f.Text, Type: f.Type}
+		counts := make(map[string]int)
+		var numbers []float64
+		var orders [][]string
+		for _, o := range s.Objects {
+			datum := o.results.Datum
+			skipped := false
+			for _, k := range datum[SkippedKey] {
+				if k == key {
+					skipped = true
+				}
+			}
+			if skipped {
+				q.Skipped++
+				continue
+			}
+			var values []string
+			for _, v := range datum[key] {
+				if strings.TrimSpace(v) != "" {
+					values = append(values, strings.TrimSpace(v))
+				}
+			}
+			switch f.Type {
+			case "checkbox", "conditional":
+				// NOTE: an unchecked box is not submitted, every (unskipped) respondent answered
+				q.Answered++
+				if len(values) > 0 {
+					counts["yes"]++
+				} else {
+					counts["no"]++
+				}
+				continue
+			}
+			if len(values) == 0 {
+				continue
+			}
+			q.Answered++
+			switch f.Type {
+			case "option", "multiselect":
+				for _, v := range values {
+					counts[v]++
+				}
+			case "number", "slide", "uslide":
+				for _, v := range values {
+					if n, ok := finite(v); ok {
+						numbers = append(numbers, n)
+					}
+				}
+			case "order":
+				orders = append(orders, values)
+			}
+		}
+		switch f.Type {
+		case "checkbox", "conditional":
+			q.Options = optionCounts([]string{"yes", "no"}, counts, q.Answered)
+		case "option", "multiselect":
+			q.Options = optionCounts(f.Options, counts, q.Answered)
+		case "number":
+			q.Stats = newNumberStats(numbers, 0, 0, false)
+		case "slide", "uslide":
+			q.Stats = newNumberStats(numbers, SlideMin, SlideMax, true)
+		case "order":
+			q.Ranks = bordaRanks(f.Options, orders)
+		}
+		summary.Questions = append(summary.Questions, q)
+	}
+	return summary
+}
+
+func (s *Summary) toHTML(w io.Writer) error {
+	tmpl, err := template.New("t").Parse(templateSummary)
+	if err != nil {
+		return err
+	}
+	return tmpl.Execute(w, s)
+}
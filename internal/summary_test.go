@@ -0,0 +1,106 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestBordaRanks(t *testing.T) {
+	tests := []struct {
+		name    string
+		options []string
+		orders  [][]string
+		expect  string
+	}{
+		{"none", []string{"a", "b"}, nil, "a:0:0.00 b:0:0.00"},
+		{"single", []string{"a", "b", "c"}, [][]string{{"c", "a", "b"}}, "c:2:1.00 a:1:2.00 b:0:3.00"},
+		{"several", []string{"a", "b", "c"}, [][]string{{"a", "b", "c"}, {"b", "a", "c"}, {"b", "c", "a"}}, "b:5:1.33 a:3:2.00 c:1:2.67"},
+		{"tie keeps option order", []string{"a", "b"}, [][]string{{"a", "b"}, {"b", "a"}}, "a:1:1.50 b:1:1.50"},
+		{"unknown option", []string{"a"}, [][]string{{"x", "a"}}, "x:1:1.00 a:0:2.00"},
+	}
+	for _, test := range tests {
+		got := ""
+		for idx, r := range bordaRanks(test.options, test.orders) {
+			if idx > 0 {
+				got += " "
+			}
+			got += fmt.Sprintf("%s:%d:%.2f", r.Option, r.Score, r.MeanRank)
+		}
+		if got != test.expect {
+			t.Errorf("%s: got %s, want %s", test.name, got, test.expect)
+		}
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	cfg := &Exports{Fields: []*ExportField{
+		{Key: "pick", Text: "Pick", Type: "option", Options: []string{"a", "b"}},
+		{Key: "num", Text: "Number", Type: "number"},
+		{Key: "slide", Text: "Slider", Type: "slide"},
+		{Key: "check", Text: "Check", Type: "checkbox"},
+		{Key: "order", Text: "Order", Type: "order", Options: []string{"x", "y"}},
+		{Text: "", Type: "label"},
+		{Text: "", Type: "conditional"},
+	}}
+	result := &StitchResult{}
+	for _, o := range []struct {
+		mode  string
+		datum map[string][]string
+	}{
+		{SaveMode, map[string][]string{"pick": {"a"}, "num": {"1"}, "slide": {"10"}, "check": {"on"}, "order": {"y", "x"}}},
+		{SaveMode, map[string][]string{"pick": {"b"}, "num": {"3"}, "slide": {"95"}, "order": {"y", "x"}}},
+		{SnapshotMode, map[string][]string{"pick": {"c"}, "num": {"NaN"}, SkippedKey: {"slide", "check"}}},
+	} {
+		result.Objects = append(result.Objects, &StitchObject{status: o.mode, results: &ResultData{Datum: o.datum}})
+	}
+	summary := result.summarize(cfg)
+	if summary.Responses != 3 || summary.Completed != 2 || summary.Snapshots != 1 || fmt.Sprintf("%.1f", summary.CompletionRate) != "66.7" {
+		t.Errorf("unexpected totals: %+v", summary)
+	}
+	if len(summary.Questions) != 5 {
+		t.Fatalf("unexpected questions: %d", len(summary.Questions))
+	}
+	tests := []struct {
+		key      string
+		answered int
+		skipped  int
+		detail   string
+	}{
+		{"pick", 3, 0, "a:1 b:1 c:1"},
+		{"num", 3, 0, "mean 2.00 median 2.00 [1.00, 3.00]"},
+		{"slide", 2, 1, "mean 52.50 median 52.50 [10.00, 95.00]"},
+		{"check", 2, 1, "yes:1 no:1"},
+		{"order", 2, 0, "y:2 x:0"},
+	}
+	for idx, test := range tests {
+		q := summary.Questions[idx]
+		detail := ""
+		for _, o := range q.Options {
+			detail += fmt.Sprintf(" %s:%d", o.Option, o.Count)
+		}
+		for _, r := range q.Ranks {
+			detail += fmt.Sprintf(" %s:%d", r.Option, r.Score)
+		}
+		if q.Stats != nil {
+			detail += fmt.Sprintf(" mean %.2f median %.2f [%.2f, %.2f]", q.Stats.Mean, q.Stats.Median, q.Stats.Min, q.Stats.Max)
+		}
+		if len(detail) > 0 {
+			detail = detail[1:]
+		}
+		if q.Key != test.key || q.Answered != test.answered || q.Skipped != test.skipped || detail != test.detail {
+			t.Errorf("%s: got %s answered %d, skipped %d, %s", test.key, q.Key, q.Answered, q.Skipped, detail)
+		}
+	}
+	if _, err := json.Marshal(summary); err != nil {
+		t.Errorf("summary can not be serialized: %v", err)
+	}
+	if stats := newNumberStats([]float64{math.NaN(), math.Inf(1)}, 0, 0, false); stats != nil {
+		t.Errorf("non-finite numbers were summarized: %v", stats)
+	}
+	slide := summary.Questions[2].Stats
+	if len(slide.Histogram) != summaryBuckets || slide.Histogram[1].Count != 1 || slide.Histogram[summaryBuckets-1].Count != 1 {
+		t.Errorf("unexpected slider histogram: %v", slide.Histogram)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"mime"
 	"net/http"
 	"path/filepath"
+	"strings"
 )
 
 const (
@@ -19,12 +20,20 @@ const (
 	TimestampKey = "timestamp"
 	// ModeKey stores underlying save mode information
 	ModeKey = "mode"
+	// SkippedKey contains the fields hidden by survey logic
+	SkippedKey = "skipped"
+	// PageKey contains the survey page a submission was made from
+	PageKey = "page"
+	// PresentedKey contains the question order presented to a session (option orders are under 'presented.<key>')
+	PresentedKey = "presented"
 	// ClientMaskMode indicates client IPs are masked when saved but shown to users
 	ClientMaskMode = "mask"
 	// ClientAnonMode indicates client IPs are not show and not saved
 	ClientAnonMode = "anon"
 	// ClientNoneMode indicates nothing is done to hide client ips
 	ClientNoneMode = "none"
+	// ClientHMACMode indicates client ips are replaced by a keyed hash (stable across restarts and runs)
+	ClientHMACMode = "hmac"
 )
 
 type (
@@ -60,57 +69,140 @@ type (
 		CondStart      bool
 		CondEnd        bool
 		HorizontalFeed bool
+		PageBreak      bool
+		Page           int
+		Checked        bool
+		Selected       map[string]bool
 		hidden         bool
 		RawType        string
+		Type           string
 		Hash           string
 		Group          string
+		ShowIf         string
+		conditions     []*Condition
+		// Key is the form (answer) key, an explicit 'id' or the numeric ID
+		Key string
+		// Piped fields reference answers in their text/description (rendered per request)
+		Piped               bool
+		Rendered            string
+		RenderedDescription string
+		// Randomize shuffles the options, RandomGroup shuffles the (contiguous) group the field is in
+		Randomize   bool
+		RandomGroup string
+	}
+	// Clause is a single comparison against a prior answer
+	Clause struct {
+		ID    string `json:"id"`
+		Op    string `json:"op"`
+		Value string `json:"value"`
+	}
+	// Condition is a set of clauses that must all hold (or must not when negated)
+	Condition struct {
+		Clauses []Clause `json:"clauses"`
+		Negate  bool     `json:"negate"`
 	}
 	// PageData represents the templating for a survey page
 	PageData struct {
+		Base        string
 		QueryParams string
 		Title       string
 		Session     string
 		Snapshot    int
+		Page        int
+		Pages       int
 		Hidden      []Field
 		Questions   []Field
+		Carried     []CarriedValue
+		Closed      string
+	}
+	// ValidationResult reports submission problems by form key
+	ValidationResult struct {
+		Errors map[string]string `json:"errors"`
+		Closed bool              `json:"closed,omitempty"`
+	}
+	// CarriedValue is an answer from another page carried along with a page submission
+	CarriedValue struct {
+		Key   string
+		Value string
 	}
 	// Configuration is the file-based configuration
 	Configuration struct {
 		Server struct {
-			Questions string
-			Bind      string
-			Snapshot  int
-			Storage   string
-			Temp      string
-			Resources string
-			Tag       string
-			Clients   string
-			Admin     struct {
+			Questions    string
+			Bind         string
+			Snapshot     int
+			Storage      string
+			Temp         string
+			Resources    string
+			Tag          string
+			Clients      string
+			Identity     string
+			Proxies      []string
+			Index        string
+			Invites      bool
+			MaskKey      string
+			PseudonymKey string
+			Mounts       map[string]MountConfig
+			Admin        struct {
 				User string
 				Pass string
 			}
 		}
+		Storage struct {
+			Backend string
+		}
 	}
 
 	// ManifestEntry represents a line in the manifest
 	ManifestEntry struct {
-		Name   string
-		Client string
-		Mask   string
-		Mode   string
-		Idx    int
+		Name    string
+		Client  string
+		Session string
+		Mask    string
+		Mode    string
+		Idx     int
+		// Results is the number of results recorded for the session, from Started until Updated
+		Results int
+		Started string
+		Updated string
+	}
+
+	// MountConfig is the configuration of a survey mounted at /s/<name>/
+	MountConfig struct {
+		Tag      string
+		Snapshot *int
+		Invites  bool
 	}
 
 	// ManifestData is how we serialize the data to the manifest
 	ManifestData struct {
+		Name      string
+		Base      string
 		Title     string
 		Tag       string
 		File      string
 		Manifest  []*ManifestEntry
 		Warning   string
-		Available []string
 		CfgName   string
 		ShowMasks bool
+		// MaxResponses and Quotas are shown with their progress when configured
+		MaxResponses int
+		Responses    int
+		Quotas       []*Quota
+		Window       string
+		Schedule     string
+		// Invites are listed (with their status) when participants are invited
+		Invite  bool
+		Invites []*InviteEntry
+	}
+
+	// AdminData is the admin page display of every survey
+	AdminData struct {
+		Title     string
+		Warning   string
+		Available []string
+		ShowMasks bool
+		Surveys   []*ManifestData
 	}
 
 	// Config represents the question configuration
@@ -121,11 +213,26 @@ type (
 
 	// Meta represents a configuration overall survey meta-definition
 	Meta struct {
-		Title string `yaml:"title"`
+		Title        string        `yaml:"title"`
+		Randomize    []string      `yaml:"randomize"`
+		MaxResponses int           `yaml:"max_responses"`
+		Quotas       []QuotaConfig `yaml:"quotas"`
+		Closed       string        `yaml:"closed"`
+		Opens        string        `yaml:"opens"`
+		Closes       string        `yaml:"closes"`
+		Grace        string        `yaml:"grace"`
+		Bundle       bool          `yaml:"bundle_on_close"`
+	}
+
+	// QuotaConfig is a limit on the completed results matching a condition (e.g. 'Role == Student')
+	QuotaConfig struct {
+		When string `yaml:"when"`
+		Max  int    `yaml:"max"`
 	}
 
 	// Question represents a single question configuration definition
 	Question struct {
+		ID          string   `yaml:"id"`
 		Text        string   `yaml:"text"`
 		Description string   `yaml:"desc"`
 		Type        string   `yaml:"type"`
@@ -136,6 +243,11 @@ type (
 		Height      string   `yaml:"height"`
 		Width       string   `yaml:"width"`
 		Group       string   `yaml:"group"`
+		ShowIf      string   `yaml:"show_if"`
+		SkipIf      string   `yaml:"skip_if"`
+		SkipTo      string   `yaml:"skip_to"`
+		Randomize   bool     `yaml:"randomize"`
+		End         bool     `yaml:"end"`
 	}
 
 	// ResultData is the resulting data from a submission
@@ -148,30 +260,49 @@ type (
 		Fields []*ExportField `json:"fields"`
 	}
 
-	// Manifest represents the actual object-definition of the manifest
+	// Manifest represents the actual object-definition of the manifest (an entry per session)
 	Manifest struct {
-		Files   []string `json:"files"`
-		Clients []string `json:"clients"`
-		Modes   []string `json:"modes"`
+		// Files, Clients, Modes (and Sessions) are the latest result of each session
+		Files    []string `json:"files"`
+		Clients  []string `json:"clients"`
+		Modes    []string `json:"modes"`
+		Sessions []string `json:"sessions,omitempty"`
+		// History is every result of each session (oldest first, only when requested see ResultStore.History)
+		History [][]ManifestResult `json:"history,omitempty"`
+	}
+
+	// ManifestResult is a result recorded for a session
+	ManifestResult struct {
+		File      string `json:"file"`
+		Mode      string `json:"mode"`
+		Timestamp string `json:"timestamp,omitempty"`
 	}
 
 	// ExportField is how fields are exported for definition
 	ExportField struct {
-		Text string `json:"text"`
-		Type string `json:"type"`
+		Key      string   `json:"key,omitempty"`
+		Text     string   `json:"text"`
+		Type     string   `json:"type"`
+		Options  []string `json:"options,omitempty"`
+		Required bool     `json:"required,omitempty"`
 	}
 )
 
-// Write writes the manifest to file
-func (manifest *Manifest) Write(filename string) {
-	datum, err := json.Marshal(manifest)
-	if err != nil {
-		Error("unable to marshal manifest", err)
-		return
+// Keys gets the numeric ID and (answer) key of the question at a position
+func (q Question) Keys(idx int) (int, string) {
+	id := idx
+	if q.Numbered > 0 {
+		id = q.Numbered
 	}
-	if err := ioutil.WriteFile(filename, datum, 0644); err != nil {
-		Error("manifest writing failure", err)
+	return id, SetIfEmpty(q.ID, fmt.Sprintf("%d", id))
+}
+
+// AnswerKey gets the key answers to the field are stored under (older run configs have no key, answers are by position)
+func (f *ExportField) AnswerKey(idx int) string {
+	if f.Key != "" {
+		return f.Key
 	}
+	return fmt.Sprintf("%d", idx)
 }
 
 // NewManifest is responsible for creating a new manifest
@@ -180,6 +311,20 @@ func NewManifest(contents []byte) (*Manifest, error) {
 	if err := json.Unmarshal(contents, &manifest); err != nil {
 		return nil, err
 	}
+	// NOTE: older manifests have no sessions (or history), they are recovered from the file names
+	if len(manifest.Sessions) == 0 {
+		for _, f := range manifest.Files {
+			manifest.Sessions = append(manifest.Sessions, sessionOf(f))
+		}
+	}
+	if len(manifest.History) == 0 {
+		for idx, f := range manifest.Files {
+			if idx >= len(manifest.Modes) {
+				break
+			}
+			manifest.History = append(manifest.History, []ManifestResult{{File: f, Mode: manifest.Modes[idx], Timestamp: timestampOf(f)}})
+		}
+	}
 	return &manifest, nil
 }
 
@@ -192,6 +337,12 @@ func (manifest *Manifest) Check() error {
 	if len(manifest.Files) != len(manifest.Modes) {
 		valid = false
 	}
+	if len(manifest.Files) != len(manifest.Sessions) {
+		valid = false
+	}
+	if len(manifest.History) > 0 && len(manifest.Files) != len(manifest.History) {
+		valid = false
+	}
 	if valid {
 		return nil
 	}
@@ -219,6 +370,25 @@ func (f *Field) Hidden() bool {
 	return f.hidden
 }
 
+// Fill sets the displayed (prior) answer of a field
+func (f *Field) Fill(values []string) {
+	if len(values) == 0 {
+		return
+	}
+	f.Value = values[0]
+	f.Checked = strings.TrimSpace(f.Value) != ""
+	f.Selected = make(map[string]bool)
+	for _, v := range values {
+		f.Selected[v] = true
+	}
+	if f.Slider && f.Checked {
+		f.Basis = f.Value
+	}
+	if f.Order && len(values) == len(f.Options) {
+		f.Options = values
+	}
+}
+
 // HandleTemplate executes a page data-based template
 func (pd *PageData) HandleTemplate(resp http.ResponseWriter, tmpl *template.Template) {
 	if err := tmpl.Execute(resp, pd); err != nil {
@@ -283,7 +453,7 @@ func GetStaticResource(staticPath, staticURL string, resp http.ResponseWriter, r
 		if err == nil {
 			notFound = false
 		} else {
-			Error(fmt.Sprintf("%s asset read failure: %v", path), err)
+			Error(fmt.Sprintf("%s asset read failure", path), err)
 		}
 	}
 	if notFound {
@@ -0,0 +1,139 @@
+package internal
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// SlideMin is the lowest value a slider can report
+	SlideMin = 0.0
+	// SlideMax is the highest value a slider can report
+	SlideMax = 100.0
+)
+
+func answered(values []string) bool {
+	for _, v := range values {
+		if strings.TrimSpace(v) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// finite parses a number, NaN and infinities are not numbers (they can not be reported)
+func finite(value string) (float64, bool) {
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0, false
+	}
+	return v, true
+}
+
+func inOptions(value string, options []string) bool {
+	for _, o := range options {
+		if o == value {
+			return true
+		}
+	}
+	return false
+}
+
+func (f Field) validate(values []string) string {
+	switch {
+	case f.Number:
+		for _, v := range values {
+			if strings.TrimSpace(v) == "" {
+				continue
+			}
+			if _, ok := finite(strings.TrimSpace(v)); !ok {
+				return "must be a number"
+			}
+		}
+	case f.Slider:
+		for _, v := range values {
+			if strings.TrimSpace(v) == "" {
+				continue
+			}
+			n, ok := finite(strings.TrimSpace(v))
+			if !ok || n < SlideMin || n > SlideMax {
+				return fmt.Sprintf("must be a number between %d and %d", int(SlideMin), int(SlideMax))
+			}
+		}
+	case f.Option:
+		if !f.Multi && len(values) > 1 {
+			return "only one option may be selected"
+		}
+		for _, v := range values {
+			if !inOptions(v, f.Options) {
+				return fmt.Sprintf("'%s' is not an available option", v)
+			}
+		}
+	case f.Order:
+		if len(values) == 0 {
+			break
+		}
+		have := append([]string{}, values...)
+		want := append([]string{}, f.Options...)
+		sort.Strings(have)
+		sort.Strings(want)
+		if strings.Join(have, "\n") != strings.Join(want, "\n") {
+			return "must be an ordering of the available options"
+		}
+	case f.Check:
+		for _, v := range values {
+			if v != "on" {
+				return "invalid checkbox value"
+			}
+		}
+	case f.HorizontalFeed, f.Image, f.Audio, f.Video, f.PageBreak, f.CondEnd:
+		if answered(values) {
+			return "does not accept answers"
+		}
+	}
+	return ""
+}
+
+// Validate checks submitted answers against the question set, returning errors by form key
+func Validate(fields []Field, answers map[string][]string, skipped []string, final bool) map[string]string {
+	errors := make(map[string]string)
+	known := make(map[string]Field)
+	for _, f := range fields {
+		known[f.Key] = f
+	}
+	for k, v := range answers {
+		switch k {
+		case SessionKey, PageKey:
+			continue
+		}
+		f, ok := known[k]
+		if !ok {
+			errors[k] = "unknown question"
+			continue
+		}
+		if msg := f.validate(v); msg != "" {
+			errors[k] = msg
+		}
+	}
+	if final {
+		hidden := make(map[string]bool)
+		for _, k := range skipped {
+			hidden[k] = true
+		}
+		for k, f := range known {
+			if f.Required == "" || hidden[k] {
+				continue
+			}
+			if _, ok := errors[k]; ok {
+				continue
+			}
+			if !answered(answers[k]) {
+				errors[k] = "an answer is required"
+			}
+		}
+	}
+	return errors
+}
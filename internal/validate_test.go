@@ -0,0 +1,60 @@
+package internal
+
+import (
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	fields := []Field{
+		{Key: "name", Required: "required"},
+		{Key: "age", Number: true},
+		{Key: "slide", Slider: true},
+		{Key: "pick", Option: true, Options: []string{"a", "b"}},
+		{Key: "multi", Option: true, Multi: true, Options: []string{"a", "b"}},
+		{Key: "order", Order: true, Options: []string{"x", "y", "z"}},
+		{Key: "check", Check: true},
+		{Key: "label", HorizontalFeed: true},
+	}
+	tests := []struct {
+		name    string
+		answers map[string][]string
+		skipped []string
+		final   bool
+		errors  map[string]string
+	}{
+		{"empty snapshot", map[string][]string{}, nil, false, map[string]string{}},
+		{"required on save", map[string][]string{}, nil, true, map[string]string{"name": "an answer is required"}},
+		{"required blank", map[string][]string{"name": {" "}}, nil, true, map[string]string{"name": "an answer is required"}},
+		{"required skipped", map[string][]string{}, []string{"name"}, true, map[string]string{}},
+		{"session and page", map[string][]string{SessionKey: {"s"}, PageKey: {"1"}, "name": {"n"}}, nil, true, map[string]string{}},
+		{"unknown", map[string][]string{"other": {"x"}}, nil, false, map[string]string{"other": "unknown question"}},
+		{"number", map[string][]string{"age": {"12.5"}}, nil, false, map[string]string{}},
+		{"not a number", map[string][]string{"age": {"twelve"}}, nil, false, map[string]string{"age": "must be a number"}},
+		{"not a finite number", map[string][]string{"age": {"NaN"}}, nil, false, map[string]string{"age": "must be a number"}},
+		{"infinite number", map[string][]string{"age": {"-Inf"}}, nil, false, map[string]string{"age": "must be a number"}},
+		{"slider not a number", map[string][]string{"slide": {"NaN"}}, nil, false, map[string]string{"slide": "must be a number between 0 and 100"}},
+		{"slider", map[string][]string{"slide": {"100"}}, nil, false, map[string]string{}},
+		{"slider range", map[string][]string{"slide": {"101"}}, nil, false, map[string]string{"slide": "must be a number between 0 and 100"}},
+		{"option", map[string][]string{"pick": {"a"}}, nil, false, map[string]string{}},
+		{"option unknown", map[string][]string{"pick": {"c"}}, nil, false, map[string]string{"pick": "'c' is not an available option"}},
+		{"option several", map[string][]string{"pick": {"a", "b"}}, nil, false, map[string]string{"pick": "only one option may be selected"}},
+		{"multiselect", map[string][]string{"multi": {"a", "b"}}, nil, false, map[string]string{}},
+		{"order", map[string][]string{"order": {"z", "x", "y"}}, nil, false, map[string]string{}},
+		{"order missing", map[string][]string{"order": {"z", "x"}}, nil, false, map[string]string{"order": "must be an ordering of the available options"}},
+		{"checkbox", map[string][]string{"check": {"on"}}, nil, false, map[string]string{}},
+		{"checkbox value", map[string][]string{"check": {"yes"}}, nil, false, map[string]string{"check": "invalid checkbox value"}},
+		{"display only", map[string][]string{"label": {"x"}}, nil, false, map[string]string{"label": "does not accept answers"}},
+	}
+	for _, test := range tests {
+		errors := Validate(fields, test.answers, test.skipped, test.final)
+		if len(errors) != len(test.errors) {
+			t.Errorf("%s: got %v, want %v", test.name, errors, test.errors)
+			continue
+		}
+		for k, v := range test.errors {
+			if errors[k] != v {
+				t.Errorf("%s: %s got '%s', want '%s'", test.name, k, errors[k], v)
+			}
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package internal
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	xlsxContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">
+<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>
+<Default Extension="xml" ContentType="application/xml"/>
+<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>
+<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>
+%s</Types>`
+	xlsxSheetType = `<Override PartName="/xl/worksheets/sheet%d.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>
+`
+	xlsxRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>
+</Relationships>`
+	xlsxWorkbook = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+<sheets>
+%s</sheets>
+</workbook>`
+	xlsxWorkbookSheet = `<sheet name="%s" sheetId="%d" r:id="rId%d"/>
+`
+	xlsxWorkbookRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+%s<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>
+</Relationships>`
+	xlsxWorkbookRel = `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet%d.xml"/>
+`
+	// NOTE: style 1 wraps text (multi-line answers), style 2 is a bold header
+	xlsxStyles = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>
+<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>
+<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>
+<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>
+<cellXfs count="3"><xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/><xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0" applyAlignment="1"><alignment wrapText="1" vertical="top"/></xf><xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/></cellXfs>
+</styleSheet>`
+	xlsxSheetStart = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<sheetViews><sheetView workbookViewId="0"><pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/></sheetView></sheetViews>
+<sheetData>
+`
+	xlsxSheetEnd = `</sheetData>
+</worksheet>`
+)
+
+type (
+	xlsxCell struct {
+		value  string
+		number bool
+	}
+
+	xlsxSheet struct {
+		name string
+		rows [][]xlsxCell
+	}
+)
+
+// xlsxColumn converts a (zero-based) column index to a spreadsheet column name (A, B, ..., AA)
+func xlsxColumn(idx int) string {
+	name := ""
+	for idx >= 0 {
+		name = string(rune('A'+idx%26)) + name
+		idx = idx/26 - 1
+	}
+	return name
+}
+
+func xlsxEscape(value string) (string, error) {
+	var b bytes.Buffer
+	if err := xml.EscapeText(&b, []byte(value)); err != nil {
+		return "", err
+	}
+	// NOTE: EscapeText encodes newlines which spreadsheets render literally
+	return strings.ReplaceAll(b.String(), "&#xA;", "\n"), nil
+}
+
+func (s *xlsxSheet) add(cells ...xlsxCell) {
+	s.rows = append(s.rows, cells)
+}
+
+func (s *xlsxSheet) xml() (string, error) {
+	var b strings.Builder
+	b.WriteString(xlsxSheetStart)
+	for r, row := range s.rows {
+		b.WriteString(fmt.Sprintf("<row r=\"%d\">", r+1))
+		style := 1
+		if r == 0 {
+			style = 2
+		}
+		for c, cell := range row {
+			if cell.value == "" {
+				continue
+			}
+			ref := fmt.Sprintf("%s%d", xlsxColumn(c), r+1)
+			if cell.number {
+				if _, ok := finite(cell.value); ok {
+					b.WriteString(fmt.Sprintf("<c r=\"%s\" s=\"%d\"><v>%s</v></c>", ref, style, cell.value))
+					continue
+				}
+			}
+			text, err := xlsxEscape(cell.value)
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(fmt.Sprintf("<c r=\"%s\" s=\"%d\" t=\"inlineStr\"><is><t xml:space=\"preserve\">%s</t></is></c>", ref, style, text))
+		}
+		b.WriteString("</row>\n")
+	}
+	b.WriteString(xlsxSheetEnd)
+	return b.String(), nil
+}
+
+func writeXLSX(out io.Writer, sheets ...*xlsxSheet) error {
+	var types, entries, rels strings.Builder
+	parts := make(map[string]string)
+	var order []string
+	for idx, sheet := range sheets {
+		num := idx + 1
+		types.WriteString(fmt.Sprintf(xlsxSheetType, num))
+		name, err := xlsxEscape(sheet.name)
+		if err != nil {
+			return err
+		}
+		entries.WriteString(fmt.Sprintf(xlsxWorkbookSheet, name, num, num))
+		rels.WriteString(fmt.Sprintf(xlsxWorkbookRel, num, num))
+		datum, err := sheet.xml()
+		if err != nil {
+			return err
+		}
+		part := fmt.Sprintf("xl/worksheets/sheet%d.xml", num)
+		parts[part] = datum
+		order = append(order, part)
+	}
+	parts["[Content_Types].xml"] = fmt.Sprintf(xlsxContentTypes, types.String())
+	parts["_rels/.rels"] = xlsxRels
+	parts["xl/workbook.xml"] = fmt.Sprintf(xlsxWorkbook, entries.String())
+	parts["xl/_rels/workbook.xml.rels"] = fmt.Sprintf(xlsxWorkbookRels, rels.String(), len(sheets)+1)
+	parts["xl/styles.xml"] = xlsxStyles
+	order = append([]string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels", "xl/styles.xml"}, order...)
+	w := zip.NewWriter(out)
+	for _, name := range order {
+		f, err := w.Create(name)
+		if err != nil {
+			return err
+		}
+		if _, err := f.Write([]byte(parts[name])); err != nil {
+			return err
+		}
+	}
+	return w.Close()
+}
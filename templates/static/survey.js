@@ -0,0 +1,125 @@
+function conditionValues(id) {
+    var values = [];
+    $('#survey_form').find('[name="' + id + '"]:enabled').each(function () {
+        if ($(this).is(':checkbox') && !$(this).is(':checked')) {
+            return;
+        }
+        var value = $(this).val();
+        if (value === null || value === undefined) {
+            return;
+        }
+        if ($.isArray(value)) {
+            values = values.concat(value);
+        } else {
+            values.push(value);
+        }
+    });
+    return $.grep(values, function (v) { return $.trim(v) !== ""; });
+}
+
+function compareNumbers(op, value, expect) {
+    var v = parseFloat(value);
+    var e = parseFloat(expect);
+    if (isNaN(v) || isNaN(e) || !isFinite(value) || !isFinite(expect)) {
+        return false;
+    }
+    switch (op) {
+        case "==": return v == e;
+        case "!=": return v != e;
+        case ">": return v > e;
+        case ">=": return v >= e;
+        case "<": return v < e;
+        case "<=": return v <= e;
+    }
+    return false;
+}
+
+function clauseMatches(clause, values) {
+    var i;
+    switch (clause.op) {
+        case "checked":
+            return values.length > 0;
+        case "==":
+            for (i = 0; i < values.length; i++) {
+                if (values[i] == clause.value || compareNumbers("==", values[i], clause.value)) {
+                    return true;
+                }
+            }
+            return false;
+        case "!=":
+            for (i = 0; i < values.length; i++) {
+                if (values[i] == clause.value || compareNumbers("==", values[i], clause.value)) {
+                    return false;
+                }
+            }
+            return true;
+    }
+    if (values.length == 0) {
+        return false;
+    }
+    return compareNumbers(clause.op, values[0], clause.value);
+}
+
+function conditionMatches(condition) {
+    var result = true;
+    $.each(condition.clauses, function (idx, clause) {
+        if (!clauseMatches(clause, conditionValues(clause.id))) {
+            result = false;
+            return false;
+        }
+    });
+    if (condition.negate) {
+        return !result;
+    }
+    return result;
+}
+
+// NOTE: rows are evaluated in document order so hidden answers never satisfy later conditions
+function applyConditions() {
+    $('#survey_form').find('[data-show-if]').each(function () {
+        var row = $(this);
+        var show = true;
+        $.each(JSON.parse(row.attr('data-show-if')), function (idx, condition) {
+            if (!conditionMatches(condition)) {
+                show = false;
+                return false;
+            }
+        });
+        row.toggle(show);
+        row.find(':input').prop('disabled', !show);
+    });
+}
+
+function clearErrors() {
+    $('.survey-error').text('');
+}
+
+// NOTE: errors are keyed by question (answer) key, anything not on this page is reported at the bottom
+function showErrors(xhr) {
+    clearErrors();
+    var result = xhr.responseJSON;
+    if (!result || !result.errors) {
+        $('#survey_errors').text('unable to save responses');
+        return;
+    }
+    var other = [];
+    $.each(result.errors, function (id, message) {
+        var elem = $('#error-' + id);
+        if (elem.length) {
+            elem.text(message);
+        } else {
+            other.push(id + ': ' + message);
+        }
+    });
+    $('#survey_errors').text(other.join(', '));
+}
+
+// NOTE: renders {{answer "key"}} references with the current answers (prior pages are carried as hidden inputs)
+function applyPipes() {
+    $('#survey_form').find('[data-pipe]').each(function () {
+        var elem = $(this);
+        elem.text(elem.attr('data-pipe').replace(/\{\{\s*answer\s+"([^"]*)"\s*\}\}/g, function (match, id) {
+            return $.map(conditionValues(id), $.trim).join(', ');
+        }));
+    });
+}
@@ -15,12 +15,13 @@ _run() {
     pkill interrogate
     ../interrogate --config settings.$1.conf &
     sleep 1
-    curl -s http://localhost:8080/survey/testid > bin/survey.$1.html
+    curl -s -b bin/cookies -c bin/cookies http://localhost:8080/survey/testid > bin/survey.$1.html
     curl -s http://localhost:8080/admin -u test:123456 > bin/admin.$1.html
-    curl -s http://localhost:8080/snapshot/ -X POST -H 'Content-Type: application/x-www-form-urlencoded; charset=UTF-8' -H 'X-Requested-With: XMLHttpRequest' --data 'session=testid&1=&0=ojioj&2=ijoiojoj&3=High&4=&6=on&7=&8=20.00&9=0&10=ijojiojoijojioi'
+    curl -s -b bin/cookies http://localhost:8080/snapshot/ -X POST -H 'Content-Type: application/x-www-form-urlencoded; charset=UTF-8' -H 'X-Requested-With: XMLHttpRequest' --data 'session=testid&1=&0=ojioj&2=ijoiojoj&3=High&4=&6=on&7=&8=20.00&9=0&10=ijojiojoijojioi'
     for f in admin survey; do
         file=bin/$f.$1.html
         sed -i "s#<td>test\_.*#<td>uid</td>#" $file
+        sed -i "s#<td>[0-9-]*T[0-9-]*</td>#<td>timestamp</td>#" $file
         diff -b -u expect/$f.$1.html $file
         if [ $? -ne 0 ]; then
             failed=1
@@ -51,4 +52,27 @@ if [ $? -ne 0 ]; then
     echo "invalid tar"
     failed=1
 fi
+test -s bin/results.xlsx
+if [ $? -ne 0 ]; then
+    echo "invalid xlsx"
+    failed=1
+fi
+../interrogate lint ../configs/*.yaml
+if [ $? -ne 0 ]; then
+    echo "invalid configs"
+    failed=1
+fi
+cp -r stitch/ bin/auto/
+../interrogate-stitcher --dir bin/auto/ --auto
+diff -b -u expect/results.csv bin/auto/stitched.test.test.csv
+if [ $? -ne 0 ]; then
+    echo "invalid auto stitch"
+    failed=1
+fi
+../interrogate-stitcher --join --out bin/results stitch/
+test -s bin/results.joined.csv
+if [ $? -ne 0 ]; then
+    echo "invalid join"
+    failed=1
+fi
 exit $failed